@@ -0,0 +1,146 @@
+package accesslog
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/zly-app/grpc/options"
+)
+
+const (
+	// 采样率
+	defSampleRate = 1
+	// 需要脱敏的字段名模式
+	defRedactFields = "password,passwd,pwd,token,*_token,secret,*_secret,*_key,authorization"
+)
+
+// 访问日志配置
+type Config struct {
+	Enable        bool     // 是否启用访问日志
+	SampleRate    float64  // 采样率, 0~1, 默认1. 错误和慢调用总是会记录
+	SlowThreshold int      // 慢调用阈值, 单位毫秒, 超过时以warn级别记录. 小于1表示不判断
+	LogPayload    bool     // 是否记录请求和响应内容, 内容会按 RedactFields 及字段选项 debug_redact 脱敏
+	RedactFields  []string // 需要脱敏的字段名模式, 忽略大小写, 支持通配符*, 如 password, *_token. 默认 password,passwd,pwd,token,*_token,secret,*_secret,*_key,authorization
+
+	// 按方法覆盖配置, key 为 /package.Service/Method, 或者 /package.Service/* 表示服务下的所有方法, 忽略大小写
+	Methods map[string]*MethodConfig
+
+	redact       *redactor
+	methodRedact sync.Map // 方法全名 -> *redactor, 合并了方法选项中的 redact_fields
+}
+
+// 方法的访问日志配置, 未设置的字段使用全局配置
+type MethodConfig struct {
+	Enable        *bool    // 是否启用访问日志
+	SampleRate    *float64 // 采样率
+	SlowThreshold *int     // 慢调用阈值, 单位毫秒
+	LogPayload    *bool    // 是否记录请求和响应内容
+}
+
+// 方法最终生效的配置
+type methodRule struct {
+	enable        bool
+	sampleRate    float64
+	slowThreshold int
+	logPayload    bool
+}
+
+func NewConfig() *Config {
+	return &Config{
+		SampleRate: defSampleRate,
+	}
+}
+
+func (conf *Config) Check() error {
+	if conf.SampleRate < 0 || conf.SampleRate > 1 {
+		return fmt.Errorf("访问日志采样率必须在0~1之间: %v", conf.SampleRate)
+	}
+	if len(conf.RedactFields) == 0 {
+		conf.RedactFields = strings.Split(defRedactFields, ",")
+	}
+	conf.redact = newRedactor(conf.RedactFields)
+
+	methods := make(map[string]*MethodConfig, len(conf.Methods))
+	for k, m := range conf.Methods {
+		if m == nil {
+			continue
+		}
+		if m.SampleRate != nil && (*m.SampleRate < 0 || *m.SampleRate > 1) {
+			return fmt.Errorf("访问日志采样率必须在0~1之间: %s: %v", k, *m.SampleRate)
+		}
+		k = strings.ToLower(k) // 配置文件中的key不区分大小写
+		if !strings.HasPrefix(k, "/") {
+			k = "/" + k
+		}
+		methods[k] = m
+	}
+	conf.Methods = methods
+	return nil
+}
+
+// 获取方法生效的配置
+func (conf *Config) rule(fullMethod string) methodRule {
+	r := methodRule{
+		enable:        conf.Enable,
+		sampleRate:    conf.SampleRate,
+		slowThreshold: conf.SlowThreshold,
+		logPayload:    conf.LogPayload,
+	}
+	if len(conf.Methods) == 0 {
+		return r
+	}
+
+	fullMethod = strings.ToLower(fullMethod)
+	m, ok := conf.Methods[fullMethod]
+	if !ok {
+		k := strings.LastIndex(fullMethod, "/")
+		if k == -1 {
+			return r
+		}
+		if m, ok = conf.Methods[fullMethod[:k]+"/*"]; !ok {
+			return r
+		}
+	}
+	if m.Enable != nil {
+		r.enable = *m.Enable
+	}
+	if m.SampleRate != nil {
+		r.sampleRate = *m.SampleRate
+	}
+	if m.SlowThreshold != nil {
+		r.slowThreshold = *m.SlowThreshold
+	}
+	if m.LogPayload != nil {
+		r.logPayload = *m.LogPayload
+	}
+	return r
+}
+
+// 获取方法的脱敏器, 方法选项中设置了 redact_fields 时与 RedactFields 合并
+func (conf *Config) redactor(fullMethod string) *redactor {
+	fields := options.Lookup(fullMethod).GetRedactFields()
+	if len(fields) == 0 {
+		return conf.redact
+	}
+	if v, ok := conf.methodRedact.Load(fullMethod); ok {
+		return v.(*redactor)
+	}
+	patterns := make([]string, 0, len(conf.RedactFields)+len(fields))
+	patterns = append(append(patterns, conf.RedactFields...), fields...)
+	v, _ := conf.methodRedact.LoadOrStore(fullMethod, newRedactor(patterns))
+	return v.(*redactor)
+}
+
+// 是否有需要记录访问日志的方法
+func (conf *Config) IsEnabled() bool {
+	if conf.Enable {
+		return true
+	}
+	for _, m := range conf.Methods {
+		if m != nil && m.Enable != nil && *m.Enable {
+			return true
+		}
+	}
+	return false
+}
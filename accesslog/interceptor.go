@@ -0,0 +1,109 @@
+package accesslog
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/zly-app/zapp/core"
+	"github.com/zly-app/zapp/filter"
+	"github.com/zly-app/zapp/pkg/utils"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/zly-app/grpc/metrics"
+	"github.com/zly-app/grpc/pkg"
+)
+
+// 服务端访问日志拦截器
+func UnaryServerInterceptor(app core.IApp, conf *Config) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		rule := conf.rule(info.FullMethod)
+		if !rule.enable {
+			return handler(ctx, req)
+		}
+
+		start := time.Now()
+		reply, err := handler(ctx, req)
+
+		mdIn, _ := metadata.FromIncomingContext(ctx)
+		callerMeta, _ := pkg.ExtractCallerMetaFromMD(mdIn)
+		p, _ := peer.FromContext(ctx)
+		write(ctx, app, conf, rule, "grpc server access", info.FullMethod, p, callerMeta, start, req, reply, err)
+		return reply, err
+	}
+}
+
+// 客户端访问日志拦截器
+func UnaryClientInterceptor(app core.IApp, conf *Config) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		rule := conf.rule(method)
+		if !rule.enable {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		start := time.Now()
+		p := &peer.Peer{}
+		err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Peer(p))...)
+
+		mdOut, _ := metadata.FromOutgoingContext(ctx)
+		callerMeta, _ := pkg.ExtractCallerMetaFromMD(mdOut)
+		write(ctx, app, conf, rule, "grpc client access", method, p, callerMeta, start, req, reply, err)
+		return err
+	}
+}
+
+func write(ctx context.Context, app core.IApp, conf *Config, rule methodRule, msg, method string, p *peer.Peer,
+	callerMeta filter.CallerMeta, start time.Time, req, reply interface{}, err error) {
+	latency := time.Since(start)
+	slow := rule.slowThreshold > 0 && latency >= time.Duration(rule.slowThreshold)*time.Millisecond
+	// 错误和慢调用总是记录
+	if err == nil && !slow && rule.sampleRate < 1 && rand.Float64() >= rule.sampleRate {
+		return
+	}
+
+	peerAddr := ""
+	if p != nil && p.Addr != nil {
+		peerAddr = p.Addr.String()
+	}
+	traceId, _ := utils.Trace.GetOTELTraceID(ctx)
+	fields := []interface{}{
+		ctx, msg,
+		zap.String("method", method),
+		zap.String("traceId", traceId),
+		zap.String("requestId", pkg.GetRequestId(ctx)),
+		zap.String("peer", peerAddr),
+		zap.String("callerService", callerMeta.CallerService),
+		zap.String("callerMethod", callerMeta.CallerMethod),
+		zap.String("callerInstance", callerMeta.CallerInstance),
+		zap.String("callerEnv", callerMeta.CallerEnv),
+		zap.String("code", status.Code(err).String()),
+		zap.Duration("latency", latency),
+		zap.Int("reqSize", metrics.MessageSize(req)),
+	}
+	if err == nil {
+		fields = append(fields, zap.Int("rspSize", metrics.MessageSize(reply)))
+	}
+	if rule.logPayload {
+		redact := conf.redactor(method)
+		fields = append(fields, zap.String("req", redact.marshal(req)))
+		if err == nil {
+			fields = append(fields, zap.String("rsp", redact.marshal(reply)))
+		}
+	}
+	if slow {
+		fields = append(fields, zap.Bool("slow", true))
+	}
+	if err != nil {
+		fields = append(fields, zap.String("err", err.Error()))
+	}
+
+	if err != nil || slow {
+		app.Warn(fields...)
+		return
+	}
+	app.Info(fields...)
+}
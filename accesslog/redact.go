@@ -0,0 +1,98 @@
+package accesslog
+
+import (
+	"path"
+	"strings"
+	"sync"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// 脱敏后的字符串
+const RedactedText = "******"
+
+// 字段脱敏器
+type redactor struct {
+	patterns []string
+	cache    sync.Map // protoreflect.FullName -> bool
+}
+
+func newRedactor(patterns []string) *redactor {
+	r := &redactor{patterns: make([]string, 0, len(patterns))}
+	for _, p := range patterns {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p != "" {
+			r.patterns = append(r.patterns, p)
+		}
+	}
+	return r
+}
+
+// 字段是否需要脱敏, 字段选项 debug_redact 为 true 或者字段名匹配模式
+func (r *redactor) isRedact(fd protoreflect.FieldDescriptor) bool {
+	if v, ok := r.cache.Load(fd.FullName()); ok {
+		return v.(bool)
+	}
+
+	redact := false
+	if opts, ok := fd.Options().(*descriptorpb.FieldOptions); ok && opts.GetDebugRedact() {
+		redact = true
+	}
+	names := []string{strings.ToLower(string(fd.Name())), strings.ToLower(fd.JSONName())}
+	for _, p := range r.patterns {
+		for _, name := range names {
+			if ok, _ := path.Match(p, name); ok {
+				redact = true
+			}
+		}
+	}
+	r.cache.Store(fd.FullName(), redact)
+	return redact
+}
+
+// 将消息脱敏后序列化为json, 非proto消息返回空
+func (r *redactor) marshal(msg interface{}) string {
+	m, ok := msg.(proto.Message)
+	if !ok || m == nil {
+		return ""
+	}
+	m = proto.Clone(m)
+	r.redactMessage(m.ProtoReflect())
+	bs, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(m)
+	if err != nil {
+		return ""
+	}
+	return string(bs)
+}
+
+func (r *redactor) redactMessage(m protoreflect.Message) {
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if r.isRedact(fd) {
+			if fd.Kind() == protoreflect.StringKind && !fd.IsList() && !fd.IsMap() {
+				m.Set(fd, protoreflect.ValueOfString(RedactedText))
+			} else {
+				m.Clear(fd)
+			}
+			return true
+		}
+
+		switch {
+		case fd.IsList() && fd.Message() != nil:
+			l := v.List()
+			for i := 0; i < l.Len(); i++ {
+				r.redactMessage(l.Get(i).Message())
+			}
+		case fd.IsMap() && fd.MapValue().Message() != nil:
+			v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+				r.redactMessage(mv.Message())
+				return true
+			})
+		case !fd.IsList() && !fd.IsMap() && fd.Message() != nil:
+			r.redactMessage(v.Message())
+		}
+		return true
+	})
+}
@@ -0,0 +1,140 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/bytedance/sonic"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/zly-app/grpc/metrics"
+)
+
+// 调试状态http路径前缀
+const HttpPathPrefix = "/debug/grpc/"
+
+// 调试状态提供者, 返回值会序列化为json
+type StateProvider func() interface{}
+
+var (
+	providers   = map[string]StateProvider{}
+	handlers    = map[string]http.Handler{}
+	providersMx sync.RWMutex
+)
+
+// 注册调试状态提供者, 可以通过 /debug/grpc/{name} 查看
+func RegisterState(name string, fn StateProvider) {
+	providersMx.Lock()
+	defer providersMx.Unlock()
+	providers[name] = fn
+}
+
+// 注册管理http处理器, 可以通过 /debug/grpc/{name} 访问, 优先于同名的调试状态
+func RegisterHandler(name string, h http.Handler) {
+	providersMx.Lock()
+	defer providersMx.Unlock()
+	handlers[name] = h
+}
+
+// 获取所有调试状态名
+func StateNames() []string {
+	providersMx.RLock()
+	defer providersMx.RUnlock()
+	names := make([]string, 0, len(providers)+len(handlers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	for name := range handlers {
+		if _, ok := providers[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 获取调试状态
+func GetState(name string) (interface{}, bool) {
+	providersMx.RLock()
+	fn, ok := providers[name]
+	providersMx.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	return fn(), true
+}
+
+// 获取管理http处理器, 包含 pprof, 指标, channelz 和所有注册的调试状态
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(PprofPathPrefix, servePprof)
+	mux.Handle(metrics.HttpPath, metrics.Handler())
+	mux.HandleFunc(HttpPathPrefix+"channelz/channels", serveChannels)
+	mux.HandleFunc(HttpPathPrefix+"channelz/servers", serveChannelzServers)
+	mux.HandleFunc(HttpPathPrefix, serveState)
+	return mux
+}
+
+func serveState(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, HttpPathPrefix), "/")
+	if name == "" {
+		paths := []string{HttpPathPrefix + "channelz/channels", HttpPathPrefix + "channelz/servers", PprofPathPrefix, metrics.HttpPath}
+		for _, n := range StateNames() {
+			paths = append(paths, HttpPathPrefix+n)
+		}
+		WriteJson(w, paths)
+		return
+	}
+
+	providersMx.RLock()
+	h, ok := handlers[name]
+	providersMx.RUnlock()
+	if ok {
+		h.ServeHTTP(w, r)
+		return
+	}
+
+	state, ok := GetState(name)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	WriteJson(w, state)
+}
+
+// 客户端连接及其子连接状态, 可以通过 target 参数过滤
+func serveChannels(w http.ResponseWriter, r *http.Request) {
+	WriteJson(w, GetChannels(r.URL.Query().Get("target")))
+}
+
+func serveChannelzServers(w http.ResponseWriter, r *http.Request) {
+	servers, err := getChannelzServers(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ret := make([]json.RawMessage, len(servers))
+	for i, s := range servers {
+		bs, err := protojson.Marshal(s)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		ret[i] = bs
+	}
+	WriteJson(w, ret)
+}
+
+// 以json格式写入响应
+func WriteJson(w http.ResponseWriter, v interface{}) {
+	bs, err := sonic.ConfigStd.MarshalIndent(v, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_, _ = w.Write(bs)
+}
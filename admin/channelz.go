@@ -0,0 +1,103 @@
+package admin
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	channelzgrpc "google.golang.org/grpc/channelz/grpc_channelz_v1"
+	"google.golang.org/grpc/channelz/service"
+)
+
+var czServer channelzgrpc.ChannelzServer
+
+// 用于获取 channelz 服务实现的注册器
+type czRegistrar struct{}
+
+func (czRegistrar) RegisterService(_ *grpc.ServiceDesc, impl interface{}) {
+	czServer = impl.(channelzgrpc.ChannelzServer)
+}
+
+func init() {
+	service.RegisterChannelzServiceToServer(czRegistrar{})
+}
+
+// 子连接状态, 状态为 READY 的子连接会被均衡器选择
+type SubChannel struct {
+	Id             int64
+	Address        string
+	State          string
+	CallsStarted   int64
+	CallsSucceeded int64
+	CallsFailed    int64
+}
+
+// 客户端连接状态
+type Channel struct {
+	Id          int64
+	Target      string
+	State       string
+	SubChannels []*SubChannel
+}
+
+// 获取指定目标的所有客户端连接及其子连接的状态, target为空表示获取所有
+func GetChannels(target string) []*Channel {
+	ctx := context.Background()
+	ret := make([]*Channel, 0)
+	start := int64(0)
+	for {
+		resp, err := czServer.GetTopChannels(ctx, &channelzgrpc.GetTopChannelsRequest{StartChannelId: start})
+		if err != nil {
+			return ret
+		}
+		for _, ch := range resp.GetChannel() {
+			start = ch.GetRef().GetChannelId() + 1
+			if target != "" && ch.GetData().GetTarget() != target {
+				continue
+			}
+			c := &Channel{
+				Id:          ch.GetRef().GetChannelId(),
+				Target:      ch.GetData().GetTarget(),
+				State:       ch.GetData().GetState().GetState().String(),
+				SubChannels: make([]*SubChannel, 0, len(ch.GetSubchannelRef())),
+			}
+			for _, ref := range ch.GetSubchannelRef() {
+				sub, err := czServer.GetSubchannel(ctx, &channelzgrpc.GetSubchannelRequest{SubchannelId: ref.GetSubchannelId()})
+				if err != nil {
+					continue
+				}
+				data := sub.GetSubchannel().GetData()
+				c.SubChannels = append(c.SubChannels, &SubChannel{
+					Id:             ref.GetSubchannelId(),
+					Address:        data.GetTarget(),
+					State:          data.GetState().GetState().String(),
+					CallsStarted:   data.GetCallsStarted(),
+					CallsSucceeded: data.GetCallsSucceeded(),
+					CallsFailed:    data.GetCallsFailed(),
+				})
+			}
+			ret = append(ret, c)
+		}
+		if resp.GetEnd() || len(resp.GetChannel()) == 0 {
+			return ret
+		}
+	}
+}
+
+// 获取 channelz 的所有服务端
+func getChannelzServers(ctx context.Context) ([]*channelzgrpc.Server, error) {
+	ret := make([]*channelzgrpc.Server, 0)
+	start := int64(0)
+	for {
+		resp, err := czServer.GetServers(ctx, &channelzgrpc.GetServersRequest{StartServerId: start})
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range resp.GetServer() {
+			start = s.GetRef().GetServerId() + 1
+			ret = append(ret, s)
+		}
+		if resp.GetEnd() || len(resp.GetServer()) == 0 {
+			return ret, nil
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/pprof"
+	"runtime/trace"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pprof http路径前缀
+const PprofPathPrefix = "/debug/pprof/"
+
+// 最大采样时间
+const maxProfileSeconds = 60
+
+/*
+pprof 处理器, 兼容 go tool pprof.
+
+	/debug/pprof/                     列出所有profile
+	/debug/pprof/{name}?debug=1       获取profile, 如 heap, goroutine, allocs, block, mutex, threadcreate
+	/debug/pprof/profile?seconds=30   cpu采样
+	/debug/pprof/trace?seconds=5      执行追踪
+
+这里不使用 net/http/pprof, 避免其在 http.DefaultServeMux 上注册路由
+*/
+func servePprof(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, PprofPathPrefix), "/")
+	switch name {
+	case "":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, p := range pprof.Profiles() {
+			_, _ = fmt.Fprintf(w, "%s %d\n", p.Name(), p.Count())
+		}
+		_, _ = fmt.Fprintln(w, "profile")
+		_, _ = fmt.Fprintln(w, "trace")
+	case "profile":
+		w.Header().Set("Content-Type", "application/octet-stream")
+		if err := pprof.StartCPUProfile(w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		sleep(r, profileSeconds(r, 30))
+		pprof.StopCPUProfile()
+	case "trace":
+		w.Header().Set("Content-Type", "application/octet-stream")
+		if err := trace.Start(w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		sleep(r, profileSeconds(r, 1))
+		trace.Stop()
+	default:
+		p := pprof.Lookup(name)
+		if p == nil {
+			http.NotFound(w, r)
+			return
+		}
+		debug, _ := strconv.Atoi(r.URL.Query().Get("debug"))
+		if debug > 0 {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		} else {
+			w.Header().Set("Content-Type", "application/octet-stream")
+		}
+		_ = p.WriteTo(w, debug)
+	}
+}
+
+func profileSeconds(r *http.Request, def int) time.Duration {
+	sec, err := strconv.Atoi(r.URL.Query().Get("seconds"))
+	if err != nil || sec <= 0 {
+		sec = def
+	}
+	if sec > maxProfileSeconds {
+		sec = maxProfileSeconds
+	}
+	return time.Duration(sec) * time.Second
+}
+
+func sleep(r *http.Request, d time.Duration) {
+	select {
+	case <-time.After(d):
+	case <-r.Context().Done():
+	}
+}
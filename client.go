@@ -14,12 +14,30 @@ type ClientConnInterface = client.ClientConnInterface
 // 创建grpc客户端建造者
 var GetClientConn = client.GetClientConn
 
+type IGRpcClientCreator = client.IGRpcClientCreator
+
+// 客户端建造者的可选接口
+type IClientPoolStatsProvider = client.IPoolStatsProvider
+type IClientDebugStateProvider = client.IDebugStateProvider
+type IClientCloser = client.IClientCloser
+
+// 替换全局的客户端建造者, 调用返回的函数恢复. 主要用于测试
+var SetClientCreator = client.SetClientCreator
+
 type ClientConn = grpc.ClientConn
 
 type UnaryInvoker = grpc.UnaryInvoker
 type CallOption = grpc.CallOption
 type ClientHook = func(ctx context.Context, method string, req, reply interface{}, cc *ClientConn, invoker UnaryInvoker, opts ...CallOption) error
 
+type ClientPoolStats = client.PoolStats
+
+// 获取客户端的连接池状态
+var GetClientPoolStats = client.GetPoolStats
+
+// 获取所有已创建客户端的连接池状态
+var GetAllClientPoolStats = client.GetAllPoolStats
+
 // 指定目标
 var WithTarget = pkg.WithTarget
 
@@ -5,24 +5,32 @@ import (
 	"fmt"
 	"net"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/zlyuancn/connpool"
+	"go.opentelemetry.io/otel/propagation"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/peer"
 
 	"github.com/zly-app/zapp/core"
 	"github.com/zly-app/zapp/filter"
 	"github.com/zly-app/zapp/pkg/utils"
 
+	"github.com/zly-app/grpc/accesslog"
 	"github.com/zly-app/grpc/balance"
 	"github.com/zly-app/grpc/discover"
 	_ "github.com/zly-app/grpc/discover/redis"
 	_ "github.com/zly-app/grpc/discover/static"
+	"github.com/zly-app/grpc/fault"
+	"github.com/zly-app/grpc/metrics"
 	"github.com/zly-app/grpc/pkg"
+	"github.com/zly-app/grpc/policy"
 	"github.com/zly-app/grpc/registry/static"
 )
 
@@ -35,6 +43,19 @@ type GRpcClient struct {
 	app        core.IApp
 	pool       connpool.IConnectPool
 	clientName string
+
+	stats             *poolStats
+	waitWarnThreshold time.Duration
+
+	propagator propagation.TextMapPropagator
+	fault      *fault.Injector
+	policy     grpc.UnaryClientInterceptor // 方法选项拦截器, 连接池中的连接共用, 以便共享响应缓存
+
+	conf    *ClientConfig
+	dType   string
+	target  string
+	builder atomic.Value // 最近一次使用的 resolver.Builder
+	conns   sync.Map     // 连接池中的连接, *grpc.ClientConn -> struct{}
 }
 
 func (g *GRpcClient) Invoke(ctx context.Context, method string, args interface{}, reply interface{}, opts ...grpc.CallOption) error {
@@ -42,9 +63,13 @@ func (g *GRpcClient) Invoke(ctx context.Context, method string, args interface{}
 	meta := filter.GetCallMeta(ctx)
 	meta.AddCallersSkip(1)
 
+	ctx = pkg.SavePropagator(ctx, g.propagator)
 	ctx, _ = pkg.TraceInjectIn(ctx)
 	err := chain.HandleInject(ctx, args, reply, func(ctx context.Context, req, rsp interface{}) error {
+		pkg.SetRpcSpanAttributes(ctx, method)
 		ctx, mdOutCopy := pkg.TraceInjectOut(ctx)
+		pkg.InjectPassThroughData(ctx, mdOutCopy) // 透传数据
+		pkg.InjectRequestId(ctx, mdOutCopy)       // 请求id
 
 		// 将主调信息传递到下游服务
 		meta := filter.GetCallMeta(ctx)
@@ -58,15 +83,18 @@ func (g *GRpcClient) Invoke(ctx context.Context, method string, args interface{}
 		ctx, opts = pkg.InjectTargetFromOpts(ctx, opts)  // 注入 target
 		ctx, opts = pkg.InjectHashKeyFromOpts(ctx, opts) // 注入 hash key
 
-		conn, err := g.pool.Get(ctx)
+		conn, err := g.getPoolConn(ctx)
 		if err != nil {
 			return err
 		}
-		defer g.pool.Put(conn)
+		defer g.putPoolConn(conn)
 
+		p := &peer.Peer{}
 		v := conn.GetConn().(*grpc.ClientConn)
-		err = v.Invoke(ctx, method, req, rsp, opts...)
+		err = v.Invoke(ctx, method, req, rsp, append(opts, grpc.Peer(p))...)
 
+		pkg.SetPeerSpanAttributes(ctx, p)
+		pkg.SetRpcSpanStatus(ctx, err, false)
 		pkg.TraceInjectGrpcHeader(ctx, opts...)
 
 		return err
@@ -74,11 +102,81 @@ func (g *GRpcClient) Invoke(ctx context.Context, method string, args interface{}
 	return err
 }
 
+/*
+创建流, 流结束前占用连接池中的一个连接.
+
+	RecvMsg 返回错误 (包括 io.EOF) 或 ctx 结束时归还连接, 调用方需要读取到流结束或取消 ctx
+	流不经过客户端过滤器和拦截器 (rpc指标, 访问日志, 故障注入, 方法选项, hook), 只传递trace, 透传数据和请求id
+*/
 func (g *GRpcClient) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
-	return nil, fmt.Errorf("当前版本不支持stream")
+	ctx = pkg.SavePropagator(ctx, g.propagator)
+	ctx, _ = pkg.TraceInjectIn(ctx)
+	ctx, mdOutCopy := pkg.TraceInjectOut(ctx)
+	pkg.InjectPassThroughData(ctx, mdOutCopy) // 透传数据
+	pkg.InjectRequestId(ctx, mdOutCopy)       // 请求id
+
+	ctx, opts = pkg.InjectTargetFromOpts(ctx, opts)  // 注入 target
+	ctx, opts = pkg.InjectHashKeyFromOpts(ctx, opts) // 注入 hash key
+
+	conn, err := g.getPoolConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	v := conn.GetConn().(*grpc.ClientConn)
+	cs, err := v.NewStream(ctx, desc, method, opts...)
+	if err != nil {
+		g.putPoolConn(conn)
+		return nil, err
+	}
+
+	s := &poolStream{ClientStream: cs, serverStreams: desc.ServerStreams, g: g, conn: conn}
+	s.mx.Lock()
+	s.stop = context.AfterFunc(ctx, s.release) // ctx结束时归还连接
+	s.mx.Unlock()
+	return s, nil
+}
+
+// 占用连接池中连接的流, 流结束时归还连接
+type poolStream struct {
+	grpc.ClientStream
+	serverStreams bool
+
+	g    *GRpcClient
+	conn *connpool.Conn
+
+	mx       sync.Mutex
+	stop     func() bool
+	released bool
+}
+
+// 归还连接, 只会归还一次
+func (s *poolStream) release() {
+	s.mx.Lock()
+	if s.released {
+		s.mx.Unlock()
+		return
+	}
+	s.released = true
+	stop := s.stop
+	s.mx.Unlock()
+
+	if stop != nil {
+		stop()
+	}
+	s.g.putPoolConn(s.conn)
+}
+
+func (s *poolStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil || !s.serverStreams { // 非服务端流只会收到一条消息
+		s.release()
+	}
+	return err
 }
 
 func (g *GRpcClient) Close() error {
+	metrics.UnRegisterPool(g.clientName)
+	fault.Unregister("client/" + g.clientName)
 	g.pool.Close()
 	return nil
 }
@@ -105,10 +203,28 @@ func NewGRpcConn(app core.IApp, name string, conf *ClientConfig) (IGrpcConn, err
 	}
 
 	g := &GRpcClient{
-		app:        app,
-		clientName: name,
+		app:               app,
+		clientName:        name,
+		stats:             &poolStats{},
+		waitWarnThreshold: time.Duration(conf.PoolWaitWarnThreshold) * time.Millisecond,
+		conf:              conf,
+	}
+	propagator, err := pkg.NewPropagator(conf.TracePropagators)
+	if err != nil {
+		return nil, err
+	}
+	g.propagator = propagator
+	g.fault, err = fault.NewInjector(conf.Fault)
+	if err != nil {
+		return nil, err
+	}
+	if conf.MethodOptions {
+		g.policy = policy.UnaryClientInterceptor()
 	}
 	dType, dAddr := g.parseAddress(conf.Address)
+	// 目标
+	target := fmt.Sprintf("%s://%s/%s", dType, "", name)
+	g.dType, g.target = strings.ToLower(dType), target
 	var creator connpool.Creator = func(ctx context.Context) (interface{}, error) {
 		// 获取发现器
 		r, err := discover.GetDiscover(app, strings.ToLower(dType), dAddr)
@@ -134,6 +250,7 @@ func NewGRpcConn(app core.IApp, name string, conf *ClientConfig) (IGrpcConn, err
 		if err != nil {
 			return nil, err
 		}
+		g.builder.Store(builder)
 		reg := grpc.WithResolvers(builder)
 
 		// 获取均衡器
@@ -142,9 +259,6 @@ func NewGRpcConn(app core.IApp, name string, conf *ClientConfig) (IGrpcConn, err
 			return nil, fmt.Errorf("获取均衡器失败: %v", err)
 		}
 
-		// 目标
-		target := fmt.Sprintf("%s://%s/%s", dType, "", name)
-
 		// 代理
 		var ss5 utils.ISocks5Proxy
 		if conf.ProxyAddress != "" {
@@ -155,7 +269,7 @@ func NewGRpcConn(app core.IApp, name string, conf *ClientConfig) (IGrpcConn, err
 			ss5 = a
 		}
 
-		v, err := makeConn(ctx, app, name, reg, balancer, target, ss5, conf)
+		v, err := makeConn(ctx, app, name, reg, balancer, target, ss5, g.fault, g.policy, conf)
 		if err != nil {
 			app.Warn(ctx, "创建conn失败", zap.String("target", target), zap.Error(err))
 		}
@@ -164,13 +278,21 @@ func NewGRpcConn(app core.IApp, name string, conf *ClientConfig) (IGrpcConn, err
 	var logCreator connpool.Creator = func(ctx context.Context) (interface{}, error) {
 		v, err := creator(ctx)
 		if err != nil {
+			atomic.AddInt64(&g.stats.createFailures, 1)
+			metrics.IncPoolCreateFailure(ctx, name)
 			app.Error(ctx, "grpc creator conn err", zap.String("name", name), zap.Error(err))
+			return v, err
 		}
+		atomic.AddInt64(&g.stats.createSuccess, 1)
+		atomic.AddInt64(&g.stats.open, 1)
+		g.conns.Store(v, struct{}{})
 		return v, err
 	}
 	var connClose connpool.ConnClose = func(conn *connpool.Conn) {
 		v, ok := conn.GetConn().(*grpc.ClientConn)
 		if ok {
+			atomic.AddInt64(&g.stats.open, -1)
+			g.conns.Delete(v)
 			_ = v.Close()
 		}
 	}
@@ -183,6 +305,8 @@ func NewGRpcConn(app core.IApp, name string, conf *ClientConfig) (IGrpcConn, err
 		return nil, fmt.Errorf("GRpcClient连接池创建失败: %v", err)
 	}
 	g.pool = pool
+	metrics.RegisterPool(name, g.poolState)
+	fault.Register("client/"+name, g.fault)
 	return g, nil
 }
 
@@ -209,7 +333,7 @@ func makePool(conf *ClientConfig, creator connpool.Creator, connClose connpool.C
 }
 
 func makeConn(ctx context.Context, app core.IApp, name string, registry, balancer grpc.DialOption, target string,
-	ss5 utils.ISocks5Proxy, conf *ClientConfig) (*grpc.ClientConn, error) {
+	ss5 utils.ISocks5Proxy, inj *fault.Injector, pol grpc.UnaryClientInterceptor, conf *ClientConfig) (*grpc.ClientConn, error) {
 	opts := []grpc.DialOption{
 		registry,
 		balancer,         // 均衡器
@@ -226,12 +350,28 @@ func makeConn(ctx context.Context, app core.IApp, name string, registry, balance
 		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials())) // 不安全连接
 	}
 
-	if ss5 != nil {
+	if ss5 != nil || hasDialer() {
 		opts = append(opts, grpc.WithContextDialer(func(ctx context.Context, s string) (net.Conn, error) {
-			return ss5.DialContext(ctx, "tcp", s)
+			if d, ok := getDialer(s); ok {
+				return d(ctx, s)
+			}
+			if ss5 != nil {
+				return ss5.DialContext(ctx, "tcp", s)
+			}
+			return (&net.Dialer{}).DialContext(ctx, "tcp", s)
 		}))
 	}
 
+	if pol != nil {
+		opts = append(opts, grpc.WithChainUnaryInterceptor(pol)) // 方法选项, 在最外层以便重试时每次调用都会记录指标和日志
+	}
+	if conf.Metrics {
+		opts = append(opts, grpc.WithChainUnaryInterceptor(metrics.UnaryClientInterceptor)) // rpc指标
+	}
+	if conf.AccessLog.IsEnabled() {
+		opts = append(opts, grpc.WithChainUnaryInterceptor(accesslog.UnaryClientInterceptor(app, conf.AccessLog))) // 访问日志
+	}
+	opts = append(opts, grpc.WithChainUnaryInterceptor(fault.UnaryClientInterceptor(inj))) // 故障注入
 	opts = append(opts,
 		grpc.WithChainUnaryInterceptor(getClientHook(name)), // 请求拦截
 	)
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"net/url"
+	"sort"
+
+	"google.golang.org/grpc"
+
+	"github.com/zly-app/grpc/admin"
+	"github.com/zly-app/grpc/discover"
+	"github.com/zly-app/grpc/pkg"
+	"github.com/zly-app/grpc/registry/static"
+)
+
+func init() {
+	admin.RegisterState("clients", func() interface{} { return GetAllDebugState() })
+}
+
+// 客户端调试状态
+type DebugState struct {
+	Name      string
+	Target    string
+	Config    ClientConfig
+	Addresses []*pkg.AddrInfo  // 发现器解析出的地址
+	Conns     []string         // 连接池中每个连接的状态
+	Channels  []*admin.Channel // 连接及其子连接的状态, 状态为 READY 的子连接会被均衡器选择
+	PoolStats PoolStats
+}
+
+// 获取客户端调试状态
+func (g *GRpcClient) DebugState() *DebugState {
+	conf := *g.conf
+	conf.ProxyAddress = redactURL(conf.ProxyAddress)
+
+	conns := make([]string, 0)
+	g.conns.Range(func(key, value any) bool {
+		conns = append(conns, key.(*grpc.ClientConn).GetState().String())
+		return true
+	})
+	sort.Strings(conns)
+
+	return &DebugState{
+		Name:      g.clientName,
+		Target:    g.target,
+		Config:    conf,
+		Addresses: g.resolvedAddress(),
+		Conns:     conns,
+		Channels:  admin.GetChannels(g.target),
+		PoolStats: g.PoolStats(),
+	}
+}
+
+// 获取发现器解析出的地址
+func (g *GRpcClient) resolvedAddress() []*pkg.AddrInfo {
+	if g.dType == static.Type {
+		return static.DefStatic.GetAddress(g.clientName)
+	}
+
+	r, ok := g.builder.Load().(*discover.Resolver)
+	if !ok {
+		return nil
+	}
+	addrList := r.GetState().Addresses
+	ret := make([]*pkg.AddrInfo, len(addrList))
+	for i, a := range addrList {
+		ret[i] = pkg.GetAddrInfo(a)
+	}
+	return ret
+}
+
+// 隐藏地址中的密码
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+	return u.Redacted()
+}
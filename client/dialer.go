@@ -0,0 +1,42 @@
+package client
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// 拨号器
+type Dialer = func(ctx context.Context, addr string) (net.Conn, error)
+
+var (
+	dialers     sync.Map // address -> Dialer
+	dialerCount atomic.Int32
+)
+
+// 注册拨号器, 连接这个地址时使用它建立连接, 如测试时连接内存中的服务. 需要在客户端创建前注册
+func RegisterDialer(address string, d Dialer) {
+	if _, loaded := dialers.Swap(address, d); !loaded {
+		dialerCount.Add(1)
+	}
+}
+
+// 取消注册拨号器
+func UnregisterDialer(address string) {
+	if _, loaded := dialers.LoadAndDelete(address); loaded {
+		dialerCount.Add(-1)
+	}
+}
+
+func hasDialer() bool {
+	return dialerCount.Load() > 0
+}
+
+func getDialer(address string) (Dialer, bool) {
+	v, ok := dialers.Load(address)
+	if !ok {
+		return nil, false
+	}
+	return v.(Dialer), true
+}
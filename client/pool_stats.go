@@ -0,0 +1,113 @@
+package client
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/zlyuancn/connpool"
+	"go.uber.org/zap"
+
+	"github.com/zly-app/grpc/metrics"
+)
+
+// 连接池状态
+type PoolStats struct {
+	Name string // 客户端名
+
+	Active  int64 // 正在使用的连接数
+	Idle    int64 // 空闲连接数
+	Open    int64 // 已建立的连接数
+	Waiting int64 // 正在等待获取连接的请求数
+
+	CreateSuccess  int64 // 创建连接成功次数
+	CreateFailures int64 // 创建连接失败次数
+	GetFailures    int64 // 获取连接失败次数, 如等待超时, 超过最大等待数
+
+	WaitCount       int64         // 获取连接的次数
+	WaitDuration    time.Duration // 获取连接的总等待耗时
+	MaxWaitDuration time.Duration // 获取连接的最大等待耗时
+}
+
+type poolStats struct {
+	active  int64
+	open    int64
+	waiting int64
+
+	createSuccess  int64
+	createFailures int64
+	getFailures    int64
+
+	waitCount       int64
+	waitDuration    int64
+	maxWaitDuration int64
+}
+
+func (g *GRpcClient) PoolStats() PoolStats {
+	s := g.stats
+	active := atomic.LoadInt64(&s.active)
+	open := atomic.LoadInt64(&s.open)
+	idle := open - active
+	if idle < 0 {
+		idle = 0
+	}
+	return PoolStats{
+		Name: g.clientName,
+
+		Active:  active,
+		Idle:    idle,
+		Open:    open,
+		Waiting: atomic.LoadInt64(&s.waiting),
+
+		CreateSuccess:  atomic.LoadInt64(&s.createSuccess),
+		CreateFailures: atomic.LoadInt64(&s.createFailures),
+		GetFailures:    atomic.LoadInt64(&s.getFailures),
+
+		WaitCount:       atomic.LoadInt64(&s.waitCount),
+		WaitDuration:    time.Duration(atomic.LoadInt64(&s.waitDuration)),
+		MaxWaitDuration: time.Duration(atomic.LoadInt64(&s.maxWaitDuration)),
+	}
+}
+
+func (g *GRpcClient) poolState() metrics.PoolState {
+	s := g.PoolStats()
+	return metrics.PoolState{Active: s.Active, Idle: s.Idle, Open: s.Open, Waiting: s.Waiting}
+}
+
+// 从连接池获取连接, 并记录等待耗时
+func (g *GRpcClient) getPoolConn(ctx context.Context) (*connpool.Conn, error) {
+	s := g.stats
+	atomic.AddInt64(&s.waiting, 1)
+	start := time.Now()
+	conn, err := g.pool.Get(ctx)
+	wait := time.Since(start)
+	atomic.AddInt64(&s.waiting, -1)
+
+	atomic.AddInt64(&s.waitCount, 1)
+	atomic.AddInt64(&s.waitDuration, int64(wait))
+	for {
+		max := atomic.LoadInt64(&s.maxWaitDuration)
+		if int64(wait) <= max || atomic.CompareAndSwapInt64(&s.maxWaitDuration, max, int64(wait)) {
+			break
+		}
+	}
+	metrics.ObservePoolWait(ctx, g.clientName, wait)
+	if g.waitWarnThreshold > 0 && wait > g.waitWarnThreshold {
+		g.app.Warn(ctx, "grpc获取连接等待时间过长", zap.String("name", g.clientName), zap.Duration("wait", wait),
+			zap.Any("stats", g.PoolStats()))
+	}
+
+	if err != nil {
+		atomic.AddInt64(&s.getFailures, 1)
+		metrics.IncPoolGetFailure(ctx, g.clientName)
+		return nil, err
+	}
+	atomic.AddInt64(&s.active, 1)
+	return conn, nil
+}
+
+// 将连接放回连接池
+func (g *GRpcClient) putPoolConn(conn *connpool.Conn) {
+	atomic.AddInt64(&g.stats.active, -1)
+	g.pool.Put(conn)
+}
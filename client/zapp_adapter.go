@@ -2,7 +2,9 @@ package client
 
 import (
 	"fmt"
+	"sort"
 	"sync"
+	"sync/atomic"
 
 	"github.com/zly-app/zapp"
 	"github.com/zly-app/zapp/component/conn"
@@ -24,6 +26,26 @@ type IGRpcClientCreator interface {
 	Close()
 }
 
+// 可选接口, 建造者实现后 GetPoolStats, GetAllPoolStats 才会返回连接池状态
+type IPoolStatsProvider interface {
+	// 获取客户端的连接池状态, 如果客户端未创建返回false
+	GetPoolStats(serverName string) (PoolStats, bool)
+	// 获取所有已创建客户端的连接池状态
+	GetAllPoolStats() []PoolStats
+}
+
+// 可选接口, 建造者实现后 GetAllDebugState 才会返回调试状态
+type IDebugStateProvider interface {
+	// 获取所有已创建客户端的调试状态, 包含解析出的地址和连接状态
+	GetAllDebugState() []*DebugState
+}
+
+// 可选接口, 建造者实现后 CloseClient 才会关闭指定的客户端
+type IClientCloser interface {
+	// 关闭指定的客户端, 下次获取时会重新创建
+	CloseClient(serverName string)
+}
+
 type instance struct {
 	cc IGrpcConn
 }
@@ -35,13 +57,17 @@ func (i *instance) Close() {
 type ClientCreatorAdapter struct {
 	app  core.IApp
 	conn *conn.AnyConn[IGrpcConn]
+
+	clients sync.Map // serverName -> *GRpcClient
 }
 
-var defGrpcClientCreator IGRpcClientCreator
+var defGrpcClientCreator *ClientCreatorAdapter
 var defGrpcClientCreatorOnce sync.Once
 
+var creatorOverride atomic.Pointer[IGRpcClientCreator]
+
 // 创建grpc客户端建造者
-func initGRpcClientCreator() IGRpcClientCreator {
+func initGRpcClientCreator() *ClientCreatorAdapter {
 	defGrpcClientCreatorOnce.Do(func() {
 		defGrpcClientCreator = &ClientCreatorAdapter{
 			app: zapp.App(),
@@ -64,8 +90,42 @@ func (c *ClientCreatorAdapter) GetClientConn(serverName string) ClientConnInterf
 	return ins
 }
 
+func (c *ClientCreatorAdapter) GetPoolStats(serverName string) (PoolStats, bool) {
+	v, ok := c.clients.Load(serverName)
+	if !ok {
+		return PoolStats{}, false
+	}
+	return v.(*GRpcClient).PoolStats(), true
+}
+
+func (c *ClientCreatorAdapter) GetAllPoolStats() []PoolStats {
+	ret := make([]PoolStats, 0)
+	c.clients.Range(func(key, value any) bool {
+		ret = append(ret, value.(*GRpcClient).PoolStats())
+		return true
+	})
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret
+}
+
+func (c *ClientCreatorAdapter) GetAllDebugState() []*DebugState {
+	ret := make([]*DebugState, 0)
+	c.clients.Range(func(key, value any) bool {
+		ret = append(ret, value.(*GRpcClient).DebugState())
+		return true
+	})
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret
+}
+
+func (c *ClientCreatorAdapter) CloseClient(serverName string) {
+	c.conn.Close(serverName)
+	c.clients.Delete(serverName)
+}
+
 func (c *ClientCreatorAdapter) Close() {
 	c.conn.CloseAll()
+	c.clients.Clear()
 }
 
 func (c *ClientCreatorAdapter) makeClient(name string) (IGrpcConn, error) {
@@ -80,12 +140,70 @@ func (c *ClientCreatorAdapter) makeClient(name string) (IGrpcConn, error) {
 	if err != nil {
 		return nil, fmt.Errorf("grpc客户端创建conn失败: %v", err)
 	}
+	if g, ok := cc.(*GRpcClient); ok {
+		c.clients.Store(name, g)
+	}
 
 	return cc, nil
 }
 
 // 获取grpc客户端conn
 func GetClientConn(serverName string) ClientConnInterface {
-	initGRpcClientCreator()
-	return defGrpcClientCreator.GetClientConn(serverName)
+	return getClientCreator().GetClientConn(serverName)
+}
+
+// 获取客户端的连接池状态, 建造者未实现 IPoolStatsProvider 时返回false
+func GetPoolStats(serverName string) (PoolStats, bool) {
+	if p, ok := getClientCreator().(IPoolStatsProvider); ok {
+		return p.GetPoolStats(serverName)
+	}
+	return PoolStats{}, false
+}
+
+// 获取所有已创建客户端的连接池状态, 建造者未实现 IPoolStatsProvider 时返回空
+func GetAllPoolStats() []PoolStats {
+	if p, ok := getClientCreator().(IPoolStatsProvider); ok {
+		return p.GetAllPoolStats()
+	}
+	return []PoolStats{}
+}
+
+// 获取所有已创建客户端的调试状态, 建造者未实现 IDebugStateProvider 时返回空
+func GetAllDebugState() []*DebugState {
+	if p, ok := getClientCreator().(IDebugStateProvider); ok {
+		return p.GetAllDebugState()
+	}
+	return []*DebugState{}
+}
+
+// 关闭指定的客户端, 下次获取时会重新创建. 建造者未实现 IClientCloser 时不做处理
+func CloseClient(serverName string) {
+	if c, ok := getClientCreator().(IClientCloser); ok {
+		c.CloseClient(serverName)
+	}
+}
+
+/*
+替换全局的客户端建造者, 之后 GetClientConn 等函数会使用它, 调用返回的函数恢复为之前的建造者.
+
+主要用于测试时将客户端替换为 fake 实现, 不需要启动服务
+*/
+func SetClientCreator(c IGRpcClientCreator) (restore func()) {
+	old := creatorOverride.Swap(&c)
+	return func() {
+		creatorOverride.Store(old)
+	}
+}
+
+// 获取默认的客户端建造者, 不受 SetClientCreator 影响
+func DefaultClientCreator() *ClientCreatorAdapter {
+	return initGRpcClientCreator()
+}
+
+// 获取当前生效的客户端建造者
+func getClientCreator() IGRpcClientCreator {
+	if c := creatorOverride.Load(); c != nil {
+		return *c
+	}
+	return initGRpcClientCreator()
 }
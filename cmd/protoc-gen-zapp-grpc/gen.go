@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+
+	"github.com/zly-app/grpc/options"
+)
+
+const (
+	contextPackage = protogen.GoImportPath("context")
+	timePackage    = protogen.GoImportPath("time")
+	zgrpcPackage   = protogen.GoImportPath("github.com/zly-app/grpc")
+)
+
+// 方法选项
+type methodOptions struct {
+	timeoutMs  uint32
+	idempotent bool
+}
+
+// 为有服务的文件生成代码
+func generate(gen *protogen.Plugin, gateway bool) error {
+	gen.SupportedFeatures = uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL)
+	for _, f := range gen.Files {
+		if !f.Generate || len(f.Services) == 0 {
+			continue
+		}
+		generateFile(gen, f, gateway)
+	}
+	return nil
+}
+
+func generateFile(gen *protogen.Plugin, file *protogen.File, gateway bool) {
+	filename := file.GeneratedFilenamePrefix + "_zapp.pb.go"
+	g := gen.NewGeneratedFile(filename, file.GoImportPath)
+	g.P("// Code generated by protoc-gen-zapp-grpc. DO NOT EDIT.")
+	g.P("// versions:")
+	g.P("// - protoc-gen-zapp-grpc ", version)
+	g.P("// - protoc               ", protocVersion(gen))
+	g.P("// source: ", file.Desc.Path())
+	g.P()
+	g.P("package ", file.GoPackageName)
+	g.P()
+	for _, s := range file.Services {
+		genService(g, file, s, gateway)
+	}
+}
+
+func genService(g *protogen.GeneratedFile, file *protogen.File, s *protogen.Service, gateway bool) {
+	svc := s.GoName
+
+	g.P("// ", svc, " 在 zapp 中的服务名, 用于 grpc.Server 和 grpc.GetClientConn")
+	g.P("const ", svc, "ServerName = ", strconv.Quote(serverName(file, s)))
+	g.P()
+
+	if len(s.Methods) > 0 {
+		durationIdent := g.QualifiedGoIdent(timePackage.Ident("Duration"))
+		millisecondIdent := g.QualifiedGoIdent(timePackage.Ident("Millisecond"))
+		g.P("// ", svc, " 的方法选项, 由 (zapp.grpc.method) 和 idempotency_level 生成. 运行时由方法选项拦截器根据proto描述生效")
+		g.P("const (")
+		for _, m := range s.Methods {
+			o := getMethodOptions(m)
+			timeout := "0"
+			if o.timeoutMs > 0 {
+				timeout = fmt.Sprintf("%d * %s", o.timeoutMs, millisecondIdent)
+			}
+			prefix := svc + "_" + m.GoName
+			g.P(prefix, "_FullMethod = ", strconv.Quote(fmt.Sprintf("/%s/%s", s.Desc.FullName(), m.Desc.Name())), " // 方法全名")
+			g.P(prefix, "_Timeout ", durationIdent, " = ", timeout, " // 调用超时, 0 表示不限制")
+			g.P(prefix, "_Idempotent = ", o.idempotent, " // 是否幂等")
+		}
+		g.P(")")
+		g.P()
+	}
+
+	g.P("// 获取 ", svc, " 客户端, 方法选项由客户端拦截器根据proto描述应用")
+	g.P("func Get", svc, "Client() ", svc, "Client {")
+	g.P("return New", svc, "Client(", zgrpcPackage.Ident("GetClientConn"), "(", svc, "ServerName))")
+	g.P("}")
+	g.P()
+
+	g.P("// 注册 ", svc, " 服务")
+	g.P("func Register", svc, "(impl ", svc, "Server, hooks ...", zgrpcPackage.Ident("ServerHook"), ") {")
+	g.P("Register", svc, "Server(", zgrpcPackage.Ident("Server"), "(", svc, "ServerName, hooks...), impl)")
+	g.P("}")
+	g.P()
+
+	if gateway && hasHttpRule(s) {
+		g.P("// 注册 ", svc, " 的网关处理器")
+		g.P("func Register", svc, "Gateway(ctx ", contextPackage.Ident("Context"), ") error {")
+		g.P("client := New", svc, "Client(", zgrpcPackage.Ident("GetGatewayClientConn"), "(", svc, "ServerName))")
+		g.P("return Register", svc, "HandlerClient(ctx, ", zgrpcPackage.Ident("GetGatewayMux"), "(), client)")
+		g.P("}")
+		g.P()
+	}
+}
+
+// 服务名, 优先使用 (zapp.grpc.service).server_name, 否则使用 proto 包名的最后一段
+func serverName(file *protogen.File, s *protogen.Service) string {
+	if o, ok := proto.GetExtension(s.Desc.Options(), options.E_Service).(*options.ServiceOptions); ok && o.GetServerName() != "" {
+		return o.GetServerName()
+	}
+	pkg := string(file.Desc.Package())
+	if pkg == "" {
+		return strings.ToLower(string(s.Desc.Name()))
+	}
+	return pkg[strings.LastIndex(pkg, ".")+1:]
+}
+
+func getMethodOptions(m *protogen.Method) methodOptions {
+	var ret methodOptions
+	opts, _ := m.Desc.Options().(*descriptorpb.MethodOptions)
+	if opts == nil {
+		return ret
+	}
+	switch opts.GetIdempotencyLevel() {
+	case descriptorpb.MethodOptions_IDEMPOTENT, descriptorpb.MethodOptions_NO_SIDE_EFFECTS:
+		ret.idempotent = true
+	}
+	if o, ok := proto.GetExtension(opts, options.E_Method).(*options.MethodOptions); ok && o != nil {
+		ret.timeoutMs = o.GetTimeoutMs()
+		ret.idempotent = ret.idempotent || o.GetIdempotent()
+	}
+	return ret
+}
+
+func hasHttpRule(s *protogen.Service) bool {
+	for _, m := range s.Methods {
+		if m.Desc.Options() != nil && proto.HasExtension(m.Desc.Options(), annotations.E_Http) {
+			return true
+		}
+	}
+	return false
+}
+
+func protocVersion(gen *protogen.Plugin) string {
+	v := gen.Request.GetCompilerVersion()
+	if v == nil {
+		return "(unknown)"
+	}
+	return fmt.Sprintf("v%d.%d.%d", v.GetMajor(), v.GetMinor(), v.GetPatch())
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+
+	"github.com/zly-app/grpc/options"
+)
+
+var update = flag.Bool("update", false, "更新 testdata 中的期望输出")
+
+func TestGenerate(t *testing.T) {
+	bs, err := os.ReadFile(filepath.Join("testdata", "fixture.textproto"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixture := &descriptorpb.FileDescriptorProto{}
+	if err = prototext.Unmarshal(bs, fixture); err != nil {
+		t.Fatalf("解析 fixture 失败: %v", err)
+	}
+
+	req := &pluginpb.CodeGeneratorRequest{
+		FileToGenerate:  []string{fixture.GetName()},
+		CompilerVersion: &pluginpb.Version{Major: proto.Int32(5), Minor: proto.Int32(29), Patch: proto.Int32(3)},
+		ProtoFile: []*descriptorpb.FileDescriptorProto{
+			protodesc.ToFileDescriptorProto(descriptorpb.File_google_protobuf_descriptor_proto),
+			protodesc.ToFileDescriptorProto(annotations.File_google_api_http_proto),
+			protodesc.ToFileDescriptorProto(annotations.File_google_api_annotations_proto),
+			protodesc.ToFileDescriptorProto(options.File_zapp_grpc_options_proto),
+			fixture,
+		},
+	}
+	gen, err := protogen.Options{}.New(req)
+	if err != nil {
+		t.Fatalf("创建插件失败: %v", err)
+	}
+	if err = generate(gen, true); err != nil {
+		t.Fatalf("生成代码失败: %v", err)
+	}
+	rsp := gen.Response()
+	if rsp.GetError() != "" {
+		t.Fatalf("生成代码失败: %s", rsp.GetError())
+	}
+	if len(rsp.GetFile()) != 1 {
+		t.Fatalf("生成的文件数错误: %d", len(rsp.GetFile()))
+	}
+
+	golden := filepath.Join("testdata", "fixture_zapp.pb.go.golden")
+	got := rsp.GetFile()[0].GetContent()
+	if *update {
+		if err = os.WriteFile(golden, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Fatalf("生成的代码和 %s 不一致, 确认改动后使用 -update 更新:\n%s", golden, got)
+	}
+}
@@ -0,0 +1,35 @@
+/*
+protoc 插件, 为 proto 中的服务生成 zapp 的客户端和服务端注册代码.
+
+	go install github.com/zly-app/grpc/cmd/protoc-gen-zapp-grpc@latest
+	protoc -I . -I $(zapp-grpc)/protos --zapp-grpc_out . --zapp-grpc_opt paths=source_relative xxx.proto
+
+参数:
+
+	gateway=false 不生成网关注册函数
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+const version = "v0.1.0"
+
+func main() {
+	showVersion := flag.Bool("version", false, "输出版本并退出")
+	flag.Parse()
+	if *showVersion {
+		fmt.Printf("protoc-gen-zapp-grpc %s\n", version)
+		return
+	}
+
+	var flags flag.FlagSet
+	gateway := flags.Bool("gateway", true, "为有 google.api.http 选项的服务生成网关注册函数")
+	protogen.Options{ParamFunc: flags.Set}.Run(func(gen *protogen.Plugin) error {
+		return generate(gen, *gateway)
+	})
+}
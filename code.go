@@ -5,10 +5,22 @@ import (
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+
+	"github.com/zly-app/grpc/pkg"
 )
 
 type Code = codes.Code
 
+// 字段校验错误
+type FieldViolation = pkg.FieldViolation
+
+// 配额不足项
+type QuotaViolation = pkg.QuotaViolation
+
+// 业务错误码
+type BizCode = pkg.BizCode
+
 // 创建一个带错误码的err
 func Error(c Code, msg string) error {
 	return status.New(c, msg).Err()
@@ -19,6 +31,11 @@ func Errorf(c Code, format string, a ...interface{}) error {
 	return Error(c, fmt.Sprintf(format, a...))
 }
 
+// 创建一个带错误码和详情的err
+func ErrorWithDetails(c Code, msg string, details ...protoadapt.MessageV1) error {
+	return pkg.WithErrDetails(Error(c, msg), details...)
+}
+
 // 获取错误状态码
 func GetErrCode(err error) Code {
 	if err == nil {
@@ -31,3 +48,54 @@ func GetErrCode(err error) Code {
 	}
 	return codes.Unknown
 }
+
+// 为err附加详情, 原有详情会保留
+var WithErrDetails = pkg.WithErrDetails
+
+// 附加请求参数错误详情
+var WithBadRequest = pkg.WithBadRequest
+
+// 附加错误信息详情
+var WithErrorInfo = pkg.WithErrorInfo
+
+// 附加重试信息详情
+var WithRetryInfo = pkg.WithRetryInfo
+
+// 附加配额不足详情
+var WithQuotaFailure = pkg.WithQuotaFailure
+
+// 附加本地化消息详情
+var WithLocalizedMessage = pkg.WithLocalizedMessage
+
+// 获取err的所有详情
+var GetErrDetails = pkg.GetErrDetails
+
+// 获取请求参数错误详情
+var GetBadRequest = pkg.GetBadRequest
+
+// 获取错误信息详情
+var GetErrorInfo = pkg.GetErrorInfo
+
+// 获取重试信息详情
+var GetRetryInfo = pkg.GetRetryInfo
+
+// 获取配额不足详情
+var GetQuotaFailure = pkg.GetQuotaFailure
+
+// 获取本地化消息详情
+var GetLocalizedMessage = pkg.GetLocalizedMessage
+
+// 注册业务错误码. httpStatus 为 0 时根据 grpc 错误码自动推导. 重复注册会 panic
+var RegisterBizCode = pkg.RegisterBizCode
+
+// 获取已注册的业务错误码
+var GetBizCode = pkg.GetBizCode
+
+// 从err中提取业务错误码
+var GetBizCodeByErr = pkg.GetBizCodeByErr
+
+// 注册消息目录, 用于按调用方语言翻译业务错误码的消息
+var RegisterMessageCatalog = pkg.RegisterMessageCatalog
+
+// 从文件系统加载消息目录, 目录下每个 json 文件为一个 locale, 如 zh-CN.json
+var LoadMessageCatalogFS = pkg.LoadMessageCatalogFS
@@ -3,17 +3,22 @@ package discover
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	"github.com/zly-app/zapp/component/conn"
 	"github.com/zly-app/zapp/core"
 	"github.com/zly-app/zapp/handler"
 	"google.golang.org/grpc/resolver"
+
+	"github.com/zly-app/grpc/admin"
 )
 
 func init() {
 	handler.AddHandler(handler.AfterExitHandler, func(app core.IApp, handlerType handler.HandlerType) {
 		discoverConn.CloseAll()
+		discovers.Clear()
 	})
+	admin.RegisterState("discover", func() interface{} { return DebugStates() })
 }
 
 type Discover interface {
@@ -46,9 +51,32 @@ func GetDiscover(app core.IApp, discoverType, discoverAddress string) (Discover,
 		return nil, err
 	}
 	r := ins.(Discover)
+	discovers.Store(key, r)
 	return r, nil
 }
 
 func AddCreator(discoverType string, d DiscoverCreator) {
 	discoverCreator[discoverType] = d
 }
+
+// 可以输出调试状态的发现器
+type DebugStater interface {
+	DebugState() interface{}
+}
+
+// 已创建的发现器, key -> Discover
+var discovers sync.Map
+
+// 获取所有已创建发现器的调试状态, key为 类型/地址
+func DebugStates() map[string]interface{} {
+	ret := make(map[string]interface{})
+	discovers.Range(func(key, value any) bool {
+		if d, ok := value.(DebugStater); ok {
+			ret[key.(string)] = d.DebugState()
+		} else {
+			ret[key.(string)] = nil
+		}
+		return true
+	})
+	return ret
+}
@@ -150,6 +150,33 @@ func (s *RedisDiscover) GetBuilder(ctx context.Context, serverName string) (reso
 	return reg.r, nil
 }
 
+// 发现的服务调试状态
+type RegServerState struct {
+	RegData []*redis_registry.RegServer // 注册数据
+	UpTime  int64                       // 更新时间, 秒级时间戳
+}
+
+// 获取调试状态, serverName -> 发现的服务
+func (s *RedisDiscover) DebugState() interface{} {
+	s.mx.Lock()
+	copyRes := make(map[string]*RegServer, len(s.res))
+	for k, v := range s.res {
+		copyRes[k] = v
+	}
+	s.mx.Unlock()
+
+	ret := make(map[string]*RegServerState, len(copyRes))
+	for serverName, reg := range copyRes {
+		reg.mx.Lock()
+		ret[serverName] = &RegServerState{
+			RegData: append([]*redis_registry.RegServer(nil), reg.regData...),
+			UpTime:  reg.upTime,
+		}
+		reg.mx.Unlock()
+	}
+	return ret
+}
+
 func (s *RedisDiscover) Close() {
 	_ = s.sub.Close()
 	if s.t != nil {
@@ -52,6 +52,16 @@ func (r *Resolver) UpdateState(s resolver.State) {
 	}
 }
 
+// 获取当前解析出的地址
+func (r *Resolver) GetState() resolver.State {
+	r.mx.RLock()
+	defer r.mx.RUnlock()
+	if r.bootstrapState == nil {
+		return resolver.State{}
+	}
+	return *r.bootstrapState
+}
+
 func (r *Resolver) removeCC(cc resolver.ClientConn) {
 	r.mx.Lock()
 	defer r.mx.Unlock()
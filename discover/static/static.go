@@ -22,6 +22,11 @@ type StaticDiscover struct{}
 
 func (s StaticDiscover) Close() {}
 
+// 获取调试状态, serverName -> 地址列表
+func (s StaticDiscover) DebugState() interface{} {
+	return static.DefStatic.DebugState()
+}
+
 func (s StaticDiscover) GetBuilder(ctx context.Context, serverName string) (resolver.Builder, error) {
 	return static.DefStatic.GetBuilder(ctx, serverName)
 }
@@ -0,0 +1,33 @@
+package fault
+
+import (
+	"net/http"
+
+	"github.com/zly-app/grpc/admin"
+)
+
+func init() {
+	admin.RegisterHandler("fault", http.HandlerFunc(serveAdmin))
+}
+
+/*
+故障注入管理.
+
+	GET  /debug/grpc/fault 查看所有故障注入器的状态
+	POST /debug/grpc/fault?name=server/hello&enable=true 开关故障注入器, name 为 * 表示所有注入器
+*/
+func serveAdmin(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		q := r.URL.Query()
+		enable, err := parseBool(q.Get("enable"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err = SetEnable(q.Get("name"), enable); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+	}
+	admin.WriteJson(w, States())
+}
@@ -0,0 +1,130 @@
+package fault
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+
+	"github.com/zly-app/grpc/pkg"
+)
+
+const (
+	// 中止请求时返回的默认错误消息
+	defAbortMessage = "fault injected"
+	// 设置了动作但未设置百分比时的默认百分比
+	defPercent = 100
+)
+
+// 故障注入配置, 用于混沌测试. 默认关闭, 可以通过 SetEnable 或管理服务在运行时开关
+type Config struct {
+	Enable bool          // 是否启用故障注入
+	Rules  []*RuleConfig // 规则, 按顺序匹配, 使用第一个匹配的规则
+}
+
+// 故障注入规则, 匹配条件都满足时生效, 未设置的条件表示不限制
+type RuleConfig struct {
+	Method        string // 匹配的方法, 如 /package.Service/Method, /package.Service/* 表示服务下的所有方法, 忽略大小写
+	CallerService string // 匹配的主调服务, 服务端为上游服务, 客户端为当前服务
+	Header        string // 匹配的元数据, 格式为 key=value, 只写 key 表示存在即可, 如 x-fault=on
+
+	DelayMs      int     // 延迟时间, 单位毫秒
+	DelayPercent float64 // 延迟的请求百分比, 0~100, 设置了 DelayMs 时默认100
+	AbortCode    string  // 中止请求时返回的错误码, 支持名称或数字, 如 Unavailable, 14
+	AbortMessage string  // 中止请求时返回的错误消息, 默认 fault injected
+	AbortPercent float64 // 中止的请求百分比, 0~100, 设置了 AbortCode 时默认100
+	DropPercent  float64 // 丢弃的请求百分比, 0~100. 丢弃的请求不会被处理, 一直等待到超时, 没有超时时间时返回 Unavailable
+}
+
+// 解析后的规则
+type rule struct {
+	conf *RuleConfig
+
+	method        string
+	callerService string
+	headerKey     string
+	headerValue   string
+	hasHeaderVal  bool
+
+	abortCode codes.Code
+}
+
+func NewConfig() *Config {
+	return &Config{}
+}
+
+func (conf *Config) Check() error {
+	_, err := parseRules(conf.Rules)
+	return err
+}
+
+func parseRules(rules []*RuleConfig) ([]*rule, error) {
+	ret := make([]*rule, 0, len(rules))
+	for i, rc := range rules {
+		if rc == nil {
+			continue
+		}
+		if err := checkPercent(rc.DelayPercent, rc.AbortPercent, rc.DropPercent); err != nil {
+			return nil, fmt.Errorf("故障注入规则[%d]: %v", i, err)
+		}
+		if rc.DelayMs > 0 && rc.DelayPercent == 0 {
+			rc.DelayPercent = defPercent
+		}
+		if rc.AbortCode != "" && rc.AbortPercent == 0 {
+			rc.AbortPercent = defPercent
+		}
+		if rc.AbortMessage == "" {
+			rc.AbortMessage = defAbortMessage
+		}
+
+		r := &rule{
+			conf:          rc,
+			method:        strings.ToLower(rc.Method), // 配置文件中的key不区分大小写
+			callerService: rc.CallerService,
+		}
+		if r.method != "" && !strings.HasPrefix(r.method, "/") {
+			r.method = "/" + r.method
+		}
+		if rc.Header != "" {
+			k, v, ok := strings.Cut(rc.Header, "=")
+			r.headerKey, r.headerValue, r.hasHeaderVal = strings.ToLower(strings.TrimSpace(k)), strings.TrimSpace(v), ok
+		}
+		if rc.AbortCode != "" {
+			c, err := pkg.ParseCode(rc.AbortCode)
+			if err != nil {
+				return nil, fmt.Errorf("故障注入规则[%d]: %v", i, err)
+			}
+			if c == codes.OK {
+				return nil, fmt.Errorf("故障注入规则[%d]: 中止错误码不能为 OK", i)
+			}
+			r.abortCode = c
+		}
+		ret = append(ret, r)
+	}
+	return ret, nil
+}
+
+func checkPercent(ps ...float64) error {
+	for _, p := range ps {
+		if p < 0 || p > 100 {
+			return fmt.Errorf("百分比必须在0~100之间: %v", p)
+		}
+	}
+	return nil
+}
+
+// 检查方法是否匹配
+func (r *rule) matchMethod(fullMethod string) bool {
+	if r.method == "" {
+		return true
+	}
+	fullMethod = strings.ToLower(fullMethod)
+	if r.method == fullMethod {
+		return true
+	}
+	if strings.HasSuffix(r.method, "/*") {
+		k := strings.LastIndex(fullMethod, "/")
+		return k != -1 && fullMethod[:k+1] == r.method[:len(r.method)-1]
+	}
+	return false
+}
@@ -0,0 +1,215 @@
+package fault
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// 故障注入器, 可以在运行时开关和替换规则
+type Injector struct {
+	enabled atomic.Bool
+	rules   atomic.Pointer[[]*rule]
+
+	delayed atomic.Int64
+	aborted atomic.Int64
+	dropped atomic.Int64
+}
+
+// 故障注入器状态
+type State struct {
+	Enable  bool
+	Rules   []*RuleConfig
+	Delayed int64 // 已延迟的请求数
+	Aborted int64 // 已中止的请求数
+	Dropped int64 // 已丢弃的请求数
+}
+
+func NewInjector(conf *Config) (*Injector, error) {
+	rules, err := parseRules(conf.Rules)
+	if err != nil {
+		return nil, err
+	}
+	i := &Injector{}
+	i.enabled.Store(conf.Enable)
+	i.rules.Store(&rules)
+	return i, nil
+}
+
+// 开关故障注入
+func (i *Injector) SetEnable(enable bool) {
+	i.enabled.Store(enable)
+}
+
+func (i *Injector) IsEnabled() bool {
+	return i.enabled.Load()
+}
+
+// 替换规则
+func (i *Injector) SetRules(rules []*RuleConfig) error {
+	rs, err := parseRules(rules)
+	if err != nil {
+		return err
+	}
+	i.rules.Store(&rs)
+	return nil
+}
+
+func (i *Injector) State() State {
+	rules := *i.rules.Load()
+	rcs := make([]*RuleConfig, len(rules))
+	for k, r := range rules {
+		rcs[k] = r.conf
+	}
+	return State{
+		Enable:  i.enabled.Load(),
+		Rules:   rcs,
+		Delayed: i.delayed.Load(),
+		Aborted: i.aborted.Load(),
+		Dropped: i.dropped.Load(),
+	}
+}
+
+/*
+对请求注入故障, 返回非nil错误时应直接返回给调用方.
+
+	callerService 主调服务
+	md 请求元数据, 服务端为 incoming 元数据, 客户端为 outgoing 元数据
+*/
+func (i *Injector) Inject(ctx context.Context, fullMethod, callerService string, md metadata.MD) error {
+	if i == nil || !i.enabled.Load() {
+		return nil
+	}
+	for _, r := range *i.rules.Load() {
+		if r.match(fullMethod, callerService, md) {
+			return i.apply(ctx, r)
+		}
+	}
+	return nil
+}
+
+func (r *rule) match(fullMethod, callerService string, md metadata.MD) bool {
+	if !r.matchMethod(fullMethod) {
+		return false
+	}
+	if r.callerService != "" && r.callerService != callerService {
+		return false
+	}
+	if r.headerKey != "" {
+		vs := md.Get(r.headerKey)
+		if len(vs) == 0 || r.hasHeaderVal && vs[0] != r.headerValue {
+			return false
+		}
+	}
+	return true
+}
+
+func (i *Injector) apply(ctx context.Context, r *rule) error {
+	c := r.conf
+	if c.DelayMs > 0 && hit(c.DelayPercent) {
+		i.delayed.Add(1)
+		t := time.NewTimer(time.Duration(c.DelayMs) * time.Millisecond)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return status.FromContextError(ctx.Err()).Err()
+		case <-t.C:
+		}
+	}
+	if hit(c.DropPercent) {
+		i.dropped.Add(1)
+		if _, ok := ctx.Deadline(); !ok {
+			return status.Error(codes.Unavailable, "request dropped by fault injection")
+		}
+		<-ctx.Done()
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	if r.abortCode != codes.OK && hit(c.AbortPercent) {
+		i.aborted.Add(1)
+		return status.Error(r.abortCode, c.AbortMessage)
+	}
+	return nil
+}
+
+func hit(percent float64) bool {
+	if percent <= 0 {
+		return false
+	}
+	return percent >= 100 || rand.Float64()*100 < percent
+}
+
+var injectors sync.Map // name -> *Injector
+
+// 注册故障注入器, 注册后可以通过名称在运行时开关, 如 server/hello, client/hello
+func Register(name string, i *Injector) {
+	injectors.Store(name, i)
+}
+
+func Unregister(name string) {
+	injectors.Delete(name)
+}
+
+func Get(name string) (*Injector, bool) {
+	v, ok := injectors.Load(name)
+	if !ok {
+		return nil, false
+	}
+	return v.(*Injector), true
+}
+
+// 开关故障注入器, name 为 * 表示所有注入器
+func SetEnable(name string, enable bool) error {
+	if name == "*" {
+		injectors.Range(func(_, v any) bool {
+			v.(*Injector).SetEnable(enable)
+			return true
+		})
+		return nil
+	}
+	i, ok := Get(name)
+	if !ok {
+		return fmt.Errorf("故障注入器不存在: %s", name)
+	}
+	i.SetEnable(enable)
+	return nil
+}
+
+// 获取所有故障注入器的名称
+func Names() []string {
+	var names []string
+	injectors.Range(func(k, _ any) bool {
+		names = append(names, k.(string))
+		return true
+	})
+	sort.Strings(names)
+	return names
+}
+
+// 获取所有故障注入器的状态
+func States() map[string]State {
+	ret := make(map[string]State)
+	injectors.Range(func(k, v any) bool {
+		ret[k.(string)] = v.(*Injector).State()
+		return true
+	})
+	return ret
+}
+
+func parseBool(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "1", "true", "on":
+		return true, nil
+	case "0", "false", "off":
+		return false, nil
+	}
+	return false, fmt.Errorf("无效的开关值: %s", s)
+}
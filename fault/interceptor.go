@@ -0,0 +1,40 @@
+package fault
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/zly-app/grpc/pkg"
+)
+
+// 服务端故障注入拦截器
+func UnaryServerInterceptor(i *Injector) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !i.IsEnabled() {
+			return handler(ctx, req)
+		}
+		mdIn, _ := metadata.FromIncomingContext(ctx)
+		callerMeta, _ := pkg.ExtractCallerMetaFromMD(mdIn)
+		if err := i.Inject(ctx, info.FullMethod, callerMeta.CallerService, mdIn); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// 客户端故障注入拦截器
+func UnaryClientInterceptor(i *Injector) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if !i.IsEnabled() {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		mdOut, _ := metadata.FromOutgoingContext(ctx)
+		callerMeta, _ := pkg.ExtractCallerMetaFromMD(mdOut)
+		if err := i.Inject(ctx, method, callerMeta.CallerService, mdOut); err != nil {
+			return err
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
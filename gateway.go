@@ -9,6 +9,7 @@ import (
 
 type GatewayMux = runtime.ServeMux
 type GatewayData = pkg.GatewayData
+type GatewayDynamicConfig = gateway.DynamicConfig
 
 // 获取网关服务mux
 var GetGatewayMux = gateway.GetGatewayMux
@@ -19,4 +20,7 @@ var GetGatewayData = pkg.GetGatewayDataByIncoming
 // 获取网关clientConn
 var GetGatewayClientConn = gateway.GetGatewayClientConn
 
+// 加载动态转码的proto描述并注册路由, 不需要网关生成的代码
+var RegisterGatewayDynamic = gateway.RegisterDynamic
+
 var WithGatewayService = gateway.WithService
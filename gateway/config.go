@@ -8,6 +8,16 @@
 
 package gateway
 
+import (
+	"fmt"
+	"regexp"
+	"text/template"
+
+	"google.golang.org/grpc/codes"
+
+	"github.com/zly-app/grpc/pkg"
+)
+
 const (
 	// bind地址
 	defBind = ":8080"
@@ -15,25 +25,135 @@ const (
 	defCloseWait = 3
 	// 运行跨域
 	defCorsAllowAll = true
+	// 是否支持 gRPC-Web
+	defGrpcWeb = false
+	// 是否支持 Connect 协议
+	defConnect = false
+	// 是否支持 WebSocket
+	defWebSocket = false
+	// SSE 保活注释的发送间隔, 单位秒
+	defStreamKeepalive = 15
+	// 响应格式
+	defEnvelope = EnvelopeWrapped
+	// 请求body的最大字节数
+	defMaxBodySize = 4 << 20
+	// 通过反射加载动态转码失败时的最长重试时间, 单位秒
+	defReflectionWait = 30
+	// 转发的网关数据中是否携带 RawBody
+	defForwardRawBody = true
+	// 转发的网关数据中是否携带 Headers
+	defForwardHeaders = true
 )
 
 type RouteConfig struct {
-	Path            string // 如 /hello/say
+	Path            string // 如 /hello/say, 也可以是带参数的路由模板, 如 /v1/items/{id}
 	HashKeyByHeader string // 从header中获取hashKey
+	Envelope        string // 路由的响应格式, 为空表示使用 ServerConfig.Envelope. 如第三方webhook可以使用 raw
+	MaxBodySize     int64  // 路由的请求body最大字节数, 0表示使用 ServerConfig.MaxBodySize, 小于0表示不限制
+	ForwardRawBody  *bool  // 是否在转发的网关数据中携带 RawBody, 为空表示使用 ServerConfig.ForwardRawBody
+	ForwardHeaders  *bool  // 是否在转发的网关数据中携带 Headers, 为空表示使用 ServerConfig.ForwardHeaders
+
+	pathRegexp *regexp.Regexp // 带参数的路由模板
+}
+
+// 动态转码配置, 根据proto描述中的 google.api.http 注解将http请求转为grpc调用, 不需要编译网关生成的代码
+type DynamicConfig struct {
+	ServerName         string   // 转发的grpc服务名, 通过 GetGatewayClientConn 获取连接
+	DescriptorSetFiles []string // FileDescriptorSet 文件路径, 由 protoc --include_imports --descriptor_set_out=xxx.pb 生成
+	Reflection         bool     // 在网关启动时通过grpc服务端反射获取proto描述, 服务端需要开启 Reflection
+	Services           []string // 暴露的服务全名, 如 hello.helloService. 为空表示所有有 google.api.http 注解的服务
+}
+
+func (conf *DynamicConfig) Check() error {
+	if conf.ServerName == "" {
+		return fmt.Errorf("动态转码的 ServerName 不能为空")
+	}
+	if len(conf.DescriptorSetFiles) == 0 && !conf.Reflection {
+		return fmt.Errorf("动态转码 %s 需要设置 DescriptorSetFiles 或开启 Reflection", conf.ServerName)
+	}
+	return nil
+}
+
+// 跨域配置
+type CorsConfig struct {
+	AllowOrigins     []string // 允许的来源, 如 https://a.com, * 表示所有. 为空时按 CorsAllowAll 处理
+	AllowMethods     []string // 允许的方法, 默认 HEAD, GET, POST, PUT, PATCH, DELETE
+	AllowHeaders     []string // 允许的请求头, * 表示浏览器请求的所有请求头, 默认 *. gRPC-Web 和 Connect 需要的请求头会自动添加
+	ExposeHeaders    []string // 允许浏览器读取的响应头, gRPC-Web 和 Connect 需要的响应头会自动添加
+	AllowCredentials bool     // 是否允许携带cookie等凭证, 只对 AllowOrigins 中明确设置的来源生效, 允许所有来源时不生效
+	MaxAge           int      // 预检请求的缓存时间, 单位秒, 小于1表示不设置
+}
+
+// gRPC-Web, Connect 和 WebSocket 协议配置
+type WebConfig struct {
+	GrpcWeb   bool                // 是否支持 gRPC-Web, 包括 application/grpc-web 和 application/grpc-web-text, 默认false
+	Connect   bool                // 是否支持 Connect 协议, 默认false
+	WebSocket bool                // 是否支持 WebSocket, 每个帧对应一个请求或响应消息, 用于双向流, 默认false
+	Services  []*WebServiceConfig // 服务转发的grpc服务名. 未设置的服务依次使用动态转码的 ServerName, 服务选项 (zapp.grpc.service).server_name, proto包名的最后一段
+}
+
+type WebServiceConfig struct {
+	Service    string // 服务全名, 如 hello.helloService, 忽略大小写
+	ServerName string // grpc服务名, 通过 GetGatewayClientConn 获取连接
+}
+
+// 流式响应配置, 服务端流方法可以通过 SSE 或 NDJSON 返回
+type StreamConfig struct {
+	Keepalive int // SSE 空闲时发送保活注释的间隔, 单位秒, 小于1表示不发送
+	Timeout   int // 流请求的最长时间, 单位秒, 小于1表示不限制. 流请求不受过滤器超时的限制, 客户端断开时会取消
 }
 
 type ServerConfig struct {
-	Bind         string // bind地址
-	CloseWait    int    // 关闭前等待处理时间, 单位秒
-	CorsAllowAll bool   // 跨域
+	Bind         string        // bind地址
+	CloseWait    int           // 关闭前等待处理时间, 单位秒
+	CorsAllowAll bool          // 允许所有来源跨域, Cors.AllowOrigins 为空时生效
+	Cors         *CorsConfig   // 跨域配置
+	Web          *WebConfig    // gRPC-Web 和 Connect 协议, 浏览器可以直接调用grpc服务
+	Stream       *StreamConfig // 流式响应配置
+
+	TracePropagators []string // trace传播器, 支持 tracecontext, baggage, b3, b3multi, jaeger. 为空表示使用otel全局传播器
+
+	MaxBodySize    int64 // 请求body的最大字节数, 超过时返回413, 0表示默认值4M, 小于0表示不限制
+	ForwardRawBody bool  // 是否在转发的网关数据中携带 RawBody, 默认true. 关闭后服务端无法通过 GetGatewayData 获取 RawBody
+	ForwardHeaders bool  // 是否在转发的网关数据中携带 Headers, 默认true. 关闭后服务端无法通过 GetGatewayData 获取 Headers
 
-	Route    []*RouteConfig // 路由配置
-	routeMap map[string]*RouteConfig
+	Envelope         string         // 响应格式, wrapped 包装为 Response, raw 直接返回proto的json, template 使用 EnvelopeTemplate. 默认 wrapped
+	EnvelopeTemplate string         // 自定义响应模板, text/template 语法, 结果必须是json. 可以使用 Response 的字段及 json 函数, 如 {"errcode":{{.Code}},"data":{{json .Data}}}
+	HttpStatus       map[string]int // grpc错误码对应的http状态码, 如 NOT_FOUND: 404, 未设置的错误码使用默认映射. 业务错误码设置的http状态码优先
+	envelopeTemplate *template.Template
+	httpStatus       map[codes.Code]int
+
+	Route       []*RouteConfig // 路由配置
+	routeMap    map[string]*RouteConfig
+	routeRegexp []*RouteConfig // 带参数的路由
+
+	Dynamic        []*DynamicConfig // 动态转码, 可以只通过配置暴露新的接口
+	ReflectionWait int              // 通过反射加载动态转码失败时的最长重试时间, 单位秒, 用于等待服务端启动. 0表示默认值30, 小于0表示不重试
 }
 
 func NewServerConfig() *ServerConfig {
 	return &ServerConfig{
-		CorsAllowAll: defCorsAllowAll,
+		CorsAllowAll:   defCorsAllowAll,
+		Cors:           &CorsConfig{},
+		Web:            NewWebConfig(),
+		Stream:         NewStreamConfig(),
+		Envelope:       defEnvelope,
+		ForwardRawBody: defForwardRawBody,
+		ForwardHeaders: defForwardHeaders,
+	}
+}
+
+func NewStreamConfig() *StreamConfig {
+	return &StreamConfig{
+		Keepalive: defStreamKeepalive,
+	}
+}
+
+func NewWebConfig() *WebConfig {
+	return &WebConfig{
+		GrpcWeb:   defGrpcWeb,
+		Connect:   defConnect,
+		WebSocket: defWebSocket,
 	}
 }
 
@@ -44,11 +164,83 @@ func (conf *ServerConfig) Check() error {
 	if conf.CloseWait < 1 {
 		conf.CloseWait = defCloseWait
 	}
+	if conf.Cors == nil {
+		conf.Cors = &CorsConfig{}
+	}
+	if conf.Web == nil {
+		conf.Web = NewWebConfig()
+	}
+	if conf.Stream == nil {
+		conf.Stream = NewStreamConfig()
+	}
+	for _, s := range conf.Web.Services {
+		if s == nil {
+			continue
+		}
+		if s.Service == "" || s.ServerName == "" {
+			return fmt.Errorf("gRPC-Web 服务配置的 Service 和 ServerName 不能为空")
+		}
+	}
+
+	if conf.ReflectionWait == 0 {
+		conf.ReflectionWait = defReflectionWait
+	}
+	if conf.MaxBodySize == 0 {
+		conf.MaxBodySize = defMaxBodySize
+	}
+	if conf.Envelope == "" {
+		conf.Envelope = defEnvelope
+	}
+	if err := checkEnvelope(conf.Envelope); err != nil {
+		return err
+	}
+	if conf.Envelope == EnvelopeTemplate || conf.EnvelopeTemplate != "" {
+		t, err := parseEnvelopeTemplate(conf.EnvelopeTemplate)
+		if err != nil {
+			return err
+		}
+		conf.envelopeTemplate = t
+	}
+	conf.httpStatus = make(map[codes.Code]int, len(conf.HttpStatus))
+	for k, v := range conf.HttpStatus {
+		c, err := pkg.ParseCode(k)
+		if err != nil {
+			return fmt.Errorf("HttpStatus 配置错误: %v", err)
+		}
+		if v < 100 || v > 599 {
+			return fmt.Errorf("HttpStatus 配置错误: 错误码 %s 的http状态码无效: %d", k, v)
+		}
+		conf.httpStatus[c] = v
+	}
 
 	conf.routeMap = make(map[string]*RouteConfig, len(conf.Route))
+	conf.routeRegexp = nil
 	for _, b := range conf.Route {
+		re, err := routePathRegexp(b.Path)
+		if err != nil {
+			return fmt.Errorf("路由 %s 配置错误: %v", b.Path, err)
+		}
+		if re != nil {
+			b.pathRegexp = re
+			conf.routeRegexp = append(conf.routeRegexp, b)
+		}
+		if err := checkEnvelope(b.Envelope); err != nil {
+			return fmt.Errorf("路由 %s 配置错误: %v", b.Path, err)
+		}
+		if b.Envelope == EnvelopeTemplate && conf.envelopeTemplate == nil {
+			return fmt.Errorf("路由 %s 配置错误: 使用 template 响应格式需要设置 EnvelopeTemplate", b.Path)
+		}
 		conf.routeMap[b.Path] = b
 	}
+
+	for _, d := range conf.Dynamic {
+		if d == nil {
+			continue
+		}
+		if err := d.Check(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
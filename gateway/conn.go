@@ -3,7 +3,6 @@ package gateway
 import (
 	"context"
 
-	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"google.golang.org/grpc"
 
 	"github.com/zly-app/grpc/client"
@@ -32,15 +31,11 @@ func (c *Conn) getContext(ctx context.Context) context.Context {
 
 // 注入hashKey用于路由分配
 func (c *Conn) injectHashKey(ctx context.Context, gd *pkg.GatewayData) context.Context {
-	path, ok := runtime.HTTPPathPattern(ctx)
-	if !ok {
+	if gd == nil || defService == nil {
 		return ctx
 	}
-	b, ok := defService.conf.GetRouteConfig(path)
-	if !ok {
-		return ctx
-	}
-	if b.HashKeyByHeader == "" {
+	b := defService.conf.routeConfig(ctx, gd.Path)
+	if b == nil || b.HashKeyByHeader == "" {
 		return ctx
 	}
 	hashKey := gd.Headers.Get(b.HashKeyByHeader)
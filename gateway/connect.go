@@ -0,0 +1,337 @@
+package gateway
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const (
+	// Connect 流的压缩标记
+	connectFlagCompressed byte = 0x01
+	// Connect 流的结束帧
+	connectFlagEndStream byte = 0x02
+)
+
+// Connect 错误码名称
+var connectCodeNames = map[codes.Code]string{
+	codes.Canceled:           "canceled",
+	codes.Unknown:            "unknown",
+	codes.InvalidArgument:    "invalid_argument",
+	codes.DeadlineExceeded:   "deadline_exceeded",
+	codes.NotFound:           "not_found",
+	codes.AlreadyExists:      "already_exists",
+	codes.PermissionDenied:   "permission_denied",
+	codes.ResourceExhausted:  "resource_exhausted",
+	codes.FailedPrecondition: "failed_precondition",
+	codes.Aborted:            "aborted",
+	codes.OutOfRange:         "out_of_range",
+	codes.Unimplemented:      "unimplemented",
+	codes.Internal:           "internal",
+	codes.Unavailable:        "unavailable",
+	codes.DataLoss:           "data_loss",
+	codes.Unauthenticated:    "unauthenticated",
+}
+
+// Connect 一元调用错误码对应的http状态码
+var connectHttpStatus = map[codes.Code]int{
+	codes.Canceled:           499,
+	codes.Unknown:            http.StatusInternalServerError,
+	codes.InvalidArgument:    http.StatusBadRequest,
+	codes.DeadlineExceeded:   http.StatusGatewayTimeout,
+	codes.NotFound:           http.StatusNotFound,
+	codes.AlreadyExists:      http.StatusConflict,
+	codes.PermissionDenied:   http.StatusForbidden,
+	codes.ResourceExhausted:  http.StatusTooManyRequests,
+	codes.FailedPrecondition: http.StatusBadRequest,
+	codes.Aborted:            http.StatusConflict,
+	codes.OutOfRange:         http.StatusBadRequest,
+	codes.Unimplemented:      http.StatusNotImplemented,
+	codes.Internal:           http.StatusInternalServerError,
+	codes.Unavailable:        http.StatusServiceUnavailable,
+	codes.DataLoss:           http.StatusInternalServerError,
+	codes.Unauthenticated:    http.StatusUnauthorized,
+}
+
+type connectError struct {
+	Code    string                `json:"code"`
+	Message string                `json:"message,omitempty"`
+	Details []*connectErrorDetail `json:"details,omitempty"`
+}
+
+type connectErrorDetail struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type connectEndStream struct {
+	Error    *connectError       `json:"error,omitempty"`
+	Metadata map[string][]string `json:"metadata,omitempty"`
+}
+
+func newConnectError(err error) *connectError {
+	s := status.Convert(err)
+	ret := &connectError{Code: connectCodeNames[s.Code()], Message: s.Message()}
+	if ret.Code == "" {
+		ret.Code = connectCodeNames[codes.Unknown]
+	}
+	for _, d := range s.Proto().GetDetails() {
+		ret.Details = append(ret.Details, &connectErrorDetail{
+			Type:  d.GetTypeUrl()[strings.LastIndex(d.GetTypeUrl(), "/")+1:],
+			Value: base64.RawStdEncoding.EncodeToString(d.GetValue()),
+		})
+	}
+	return ret
+}
+
+// Connect 的消息编解码, json 编码需要方法描述
+type connectCodec struct {
+	json bool
+	md   protoreflect.MethodDescriptor
+}
+
+func newConnectCodec(g *Gateway, subtype, fullMethod string) (*connectCodec, error) {
+	switch subtype {
+	case "proto":
+		return &connectCodec{}, nil
+	case "json":
+		md := g.findMethod(fullMethod)
+		if md == nil {
+			return nil, status.Errorf(codes.Unimplemented, "未找到方法 %s 的描述, 不支持json编码", fullMethod)
+		}
+		return &connectCodec{json: true, md: md}, nil
+	}
+	return nil, status.Errorf(codes.Unimplemented, "不支持的编码: %s", subtype)
+}
+
+// 将请求转为proto二进制数据
+func (c *connectCodec) decodeRequest(data []byte) ([]byte, error) {
+	if !c.json {
+		return data, nil
+	}
+	msg := dynamicpb.NewMessage(c.md.Input())
+	if len(data) > 0 {
+		if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, msg); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "解析请求失败: %v", err)
+		}
+	}
+	return proto.Marshal(msg)
+}
+
+// 将proto二进制响应转为请求的编码
+func (c *connectCodec) encodeResponse(data []byte) ([]byte, error) {
+	if !c.json {
+		return data, nil
+	}
+	msg := dynamicpb.NewMessage(c.md.Output())
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return nil, status.Errorf(codes.Internal, "解析响应失败: %v", err)
+	}
+	return protojson.Marshal(msg)
+}
+
+// 从 Connect-Timeout-Ms 设置超时
+func connectTimeout(ctx context.Context, r *http.Request) (context.Context, context.CancelFunc, error) {
+	v := r.Header.Get("Connect-Timeout-Ms")
+	if v == "" {
+		return ctx, func() {}, nil
+	}
+	ms, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || ms < 0 {
+		return ctx, func() {}, status.Errorf(codes.InvalidArgument, "无效的 Connect-Timeout-Ms: %s", v)
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(ms)*time.Millisecond)
+	return ctx, cancel, nil
+}
+
+// 处理 Connect 一元请求
+func (g *Gateway) serveConnectUnary(w http.ResponseWriter, r *http.Request) {
+	ct := r.Header.Get("Content-Type")
+	subtype := strings.TrimPrefix(strings.TrimSpace(strings.Split(ct, ";")[0]), "application/")
+
+	var header, trailer metadata.MD
+	var rsp []byte
+	err := func() error {
+		fullMethod := r.URL.Path
+		service, err := parseWebMethod(fullMethod)
+		if err != nil {
+			return err
+		}
+		codec, err := newConnectCodec(g, subtype, fullMethod)
+		if err != nil {
+			return err
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "读取请求失败: %v", err)
+		}
+		if r.Header.Get("Content-Encoding") != "" && r.Header.Get("Content-Encoding") != "identity" {
+			return status.Error(codes.Unimplemented, "不支持压缩的消息")
+		}
+		req, err := codec.decodeRequest(body)
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel, err := connectTimeout(r.Context(), r)
+		if err != nil {
+			return err
+		}
+		defer cancel()
+		ctx, err = g.webContext(ctx, r, fullMethod)
+		if err != nil {
+			return err
+		}
+		trailer, err = webInvoke(ctx, GetGatewayClientConn(g.webServerName(service)), fullMethod, false, req,
+			func(md metadata.MD) { header = md },
+			func(data []byte) (err error) {
+				rsp, err = codec.encodeResponse(data)
+				return err
+			})
+		return err
+	}()
+
+	writeMetadataHeader(w.Header(), header, "")
+	writeMetadataHeader(w.Header(), trailer, "Trailer-")
+	if err != nil {
+		ce := newConnectError(err)
+		bs, _ := json.Marshal(ce)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(connectHttpStatus[status.Code(err)])
+		_, _ = w.Write(bs)
+		return
+	}
+	w.Header().Set("Content-Type", ct)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(rsp)
+}
+
+// 处理 Connect 流请求, 支持服务端流
+func (g *Gateway) serveConnectStream(w http.ResponseWriter, r *http.Request) {
+	ct := r.Header.Get("Content-Type")
+	subtype := strings.TrimPrefix(strings.TrimSpace(strings.Split(ct, ";")[0]), "application/connect+")
+	cw := &connectStreamWriter{w: w}
+	w.Header().Set("Content-Type", ct)
+
+	var trailer metadata.MD
+	err := func() error {
+		fullMethod := r.URL.Path
+		service, err := parseWebMethod(fullMethod)
+		if err != nil {
+			return err
+		}
+		if md := g.findMethod(fullMethod); md != nil && md.IsStreamingClient() {
+			return status.Error(codes.Unimplemented, "Connect 不支持客户端流")
+		}
+		codec, err := newConnectCodec(g, subtype, fullMethod)
+		if err != nil {
+			return err
+		}
+		body, err := readConnectEnvelope(r.Body)
+		if err != nil {
+			return err
+		}
+		req, err := codec.decodeRequest(body)
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel, err := connectTimeout(r.Context(), r)
+		if err != nil {
+			return err
+		}
+		defer cancel()
+		ctx, err = g.webContext(ctx, r, fullMethod)
+		if err != nil {
+			return err
+		}
+		trailer, err = webInvoke(ctx, GetGatewayClientConn(g.webServerName(service)), fullMethod, true, req,
+			cw.writeHeader,
+			func(data []byte) error {
+				bs, err := codec.encodeResponse(data)
+				if err != nil {
+					return err
+				}
+				return cw.writeFrame(0, bs)
+			})
+		return err
+	}()
+	cw.finish(trailer, err)
+}
+
+// 读取 Connect 流请求中的第一个消息
+func readConnectEnvelope(body io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "读取请求失败: %v", err)
+	}
+	if len(data) < 5 {
+		return nil, status.Error(codes.InvalidArgument, "请求缺少消息")
+	}
+	if data[0]&connectFlagCompressed != 0 {
+		return nil, status.Error(codes.Unimplemented, "不支持压缩的消息")
+	}
+	n := binary.BigEndian.Uint32(data[1:5])
+	if uint32(len(data)-5) < n {
+		return nil, status.Error(codes.InvalidArgument, "请求消息不完整")
+	}
+	return data[5 : 5+n], nil
+}
+
+type connectStreamWriter struct {
+	w           http.ResponseWriter
+	wroteHeader bool
+}
+
+func (cw *connectStreamWriter) writeHeader(md metadata.MD) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+	writeMetadataHeader(cw.w.Header(), md, "")
+	cw.w.WriteHeader(http.StatusOK)
+}
+
+func (cw *connectStreamWriter) writeFrame(flag byte, data []byte) error {
+	frame := make([]byte, 5+len(data))
+	frame[0] = flag
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(data)))
+	copy(frame[5:], data)
+	if _, err := cw.w.Write(frame); err != nil {
+		return err
+	}
+	if f, ok := cw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// 写入结束帧, 包含错误及尾部元数据
+func (cw *connectStreamWriter) finish(trailer metadata.MD, err error) {
+	cw.writeHeader(nil)
+	end := &connectEndStream{}
+	if err != nil {
+		end.Error = newConnectError(err)
+	}
+	if len(trailer) > 0 {
+		h := http.Header{}
+		writeMetadataHeader(h, trailer, "")
+		end.Metadata = h
+	}
+	bs, _ := json.Marshal(end)
+	_ = cw.writeFrame(connectFlagEndStream, bs)
+}
@@ -0,0 +1,134 @@
+package gateway
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	// 默认允许的方法
+	defCorsAllowMethods = []string{"HEAD", "GET", "POST", "PUT", "PATCH", "DELETE"}
+	// gRPC-Web 和 Connect 需要的请求头
+	webAllowHeaders = []string{"Content-Type", "X-Grpc-Web", "X-User-Agent", "Grpc-Timeout",
+		"Connect-Protocol-Version", "Connect-Timeout-Ms", "Connect-Accept-Encoding", "Connect-Content-Encoding"}
+	// gRPC-Web 和 Connect 需要暴露的响应头
+	webExposeHeaders = []string{"Grpc-Status", "Grpc-Message", "Grpc-Status-Details-Bin",
+		"Connect-Content-Encoding", "Connect-Accept-Encoding", "X-Request-Id"}
+)
+
+// 跨域处理
+type cors struct {
+	allowAll         bool
+	origins          map[string]struct{}
+	allowMethods     string
+	allowHeaders     []string
+	allowAllHeaders  bool
+	exposeHeaders    string
+	allowCredentials bool
+	maxAge           string
+}
+
+// 创建跨域处理, 没有允许的来源时返回 nil
+func newCors(conf *ServerConfig) *cors {
+	cc := conf.Cors
+	origins := cc.AllowOrigins
+	if len(origins) == 0 {
+		if !conf.CorsAllowAll {
+			return nil
+		}
+		origins = []string{"*"}
+	}
+
+	c := &cors{
+		origins:          make(map[string]struct{}, len(origins)),
+		allowCredentials: cc.AllowCredentials,
+	}
+	for _, o := range origins {
+		if o == "*" {
+			c.allowAll = true
+		}
+		c.origins[strings.TrimSuffix(o, "/")] = struct{}{}
+	}
+	// 允许所有来源时返回 *, 不允许携带凭证, 否则任意网站都可以带着用户的cookie调用并读取响应
+	if c.allowAll {
+		c.allowCredentials = false
+	}
+
+	methods := cc.AllowMethods
+	if len(methods) == 0 {
+		methods = defCorsAllowMethods
+	}
+	c.allowMethods = strings.Join(methods, ", ")
+
+	headers := cc.AllowHeaders
+	if len(headers) == 0 {
+		headers = []string{"*"}
+	}
+	for _, h := range headers {
+		if h == "*" {
+			c.allowAllHeaders = true
+			continue
+		}
+		c.allowHeaders = append(c.allowHeaders, h)
+	}
+	c.allowHeaders = append(c.allowHeaders, webAllowHeaders...)
+	c.exposeHeaders = strings.Join(append(append([]string{}, cc.ExposeHeaders...), webExposeHeaders...), ", ")
+	if cc.MaxAge > 0 {
+		c.maxAge = strconv.Itoa(cc.MaxAge)
+	}
+	return c
+}
+
+func (c *cors) allowOrigin(origin string) bool {
+	if c.allowAll {
+		return true
+	}
+	_, ok := c.origins[origin]
+	return ok
+}
+
+// 是否为配置中明确允许的来源, 不包括 *
+func (c *cors) allowExplicitOrigin(origin string) bool {
+	if origin == "*" {
+		return false
+	}
+	_, ok := c.origins[origin]
+	return ok
+}
+
+func (c *cors) handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin != "" && c.allowOrigin(origin) {
+			header := w.Header()
+			if c.allowAll {
+				header.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				header.Set("Access-Control-Allow-Origin", origin)
+				header.Add("Vary", "Origin")
+			}
+			if c.allowCredentials {
+				header.Set("Access-Control-Allow-Credentials", "true")
+			}
+			header.Set("Access-Control-Expose-Headers", c.exposeHeaders)
+
+			if r.Method == http.MethodOptions {
+				header.Set("Access-Control-Allow-Methods", c.allowMethods)
+				allowHeaders := c.allowHeaders
+				if reqHeaders := r.Header.Get("Access-Control-Request-Headers"); c.allowAllHeaders && reqHeaders != "" {
+					allowHeaders = append([]string{reqHeaders}, allowHeaders...)
+				}
+				header.Set("Access-Control-Allow-Headers", strings.Join(allowHeaders, ", "))
+				if c.maxAge != "" {
+					header.Set("Access-Control-Max-Age", c.maxAge)
+				}
+			}
+		}
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,175 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// 加载动态转码的proto描述并注册路由, 需要在网关启动前调用
+func (g *Gateway) RegisterDynamic(ctx context.Context, conf *DynamicConfig) error {
+	if err := conf.Check(); err != nil {
+		return err
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+	for _, f := range conf.DescriptorSetFiles {
+		s, err := readDescriptorSetFile(f)
+		if err != nil {
+			return err
+		}
+		set.File = append(set.File, s.File...)
+	}
+	if conf.Reflection {
+		s, err := fetchDescriptorSetByReflection(ctx, GetGatewayClientConn(conf.ServerName), conf.Services)
+		if err != nil {
+			return fmt.Errorf("通过反射获取 %s 的proto描述失败: %v", conf.ServerName, err)
+		}
+		set.File = append(set.File, s.File...)
+	}
+
+	files, err := newFiles(set)
+	if err != nil {
+		return fmt.Errorf("解析 %s 的proto描述失败: %v", conf.ServerName, err)
+	}
+	services, err := selectServices(files, conf.Services)
+	if err != nil {
+		return err
+	}
+
+	count := 0
+	for _, sd := range services {
+		g.dynamicServices.Store(string(sd.FullName()), conf.ServerName)
+		for i := 0; i < sd.Methods().Len(); i++ {
+			md := sd.Methods().Get(i)
+			g.dynamicMethods.Store("/"+string(sd.FullName())+"/"+string(md.Name()), md)
+			rule, ok := proto.GetExtension(md.Options(), annotations.E_Http).(*annotations.HttpRule)
+			if !ok || rule == nil {
+				continue
+			}
+			if md.IsStreamingClient() {
+				g.app.Warn("grpc网关动态转码不支持客户端流方法", zap.String("method", string(md.FullName())))
+				continue
+			}
+			n, err := g.registerDynamicMethod(conf.ServerName, md, rule)
+			if err != nil {
+				return err
+			}
+			count += n
+		}
+	}
+	g.app.Info("grpc网关动态转码已加载", zap.String("serverName", conf.ServerName), zap.Int("services", len(services)), zap.Int("routes", count))
+	return nil
+}
+
+// 注册方法的路由, 包括附加绑定, 返回注册的路由数
+func (g *Gateway) registerDynamicMethod(serverName string, md protoreflect.MethodDescriptor, rule *annotations.HttpRule) (int, error) {
+	rules := append([]*annotations.HttpRule{rule}, rule.GetAdditionalBindings()...)
+	for _, r := range rules {
+		httpMethod, pattern := httpRulePattern(r)
+		if pattern == "" {
+			return 0, fmt.Errorf("方法 %s 的 google.api.http 注解没有路径", md.FullName())
+		}
+		m, err := newDynamicMethod(serverName, md, r, pattern)
+		if err != nil {
+			return 0, err
+		}
+		if err = g.gwMux.HandlePath(httpMethod, pattern, g.dynamicHandler(m)); err != nil {
+			return 0, fmt.Errorf("注册方法 %s 的路由 %s 失败: %v", md.FullName(), pattern, err)
+		}
+	}
+	return len(rules), nil
+}
+
+func httpRulePattern(r *annotations.HttpRule) (string, string) {
+	switch p := r.GetPattern().(type) {
+	case *annotations.HttpRule_Get:
+		return "GET", p.Get
+	case *annotations.HttpRule_Put:
+		return "PUT", p.Put
+	case *annotations.HttpRule_Post:
+		return "POST", p.Post
+	case *annotations.HttpRule_Delete:
+		return "DELETE", p.Delete
+	case *annotations.HttpRule_Patch:
+		return "PATCH", p.Patch
+	case *annotations.HttpRule_Custom:
+		return strings.ToUpper(p.Custom.GetKind()), p.Custom.GetPath()
+	}
+	return "", ""
+}
+
+func readDescriptorSetFile(file string) (*descriptorpb.FileDescriptorSet, error) {
+	bs, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("读取proto描述文件失败: %v", err)
+	}
+	set := &descriptorpb.FileDescriptorSet{}
+	if err = proto.Unmarshal(bs, set); err != nil {
+		return nil, fmt.Errorf("解析proto描述文件 %s 失败: %v", file, err)
+	}
+	return set, nil
+}
+
+// 构建proto描述, 去除重复的文件, 缺少的依赖从全局注册表中补充
+func newFiles(set *descriptorpb.FileDescriptorSet) (*protoregistry.Files, error) {
+	ret := &descriptorpb.FileDescriptorSet{}
+	seen := make(map[string]struct{}, len(set.File))
+	for _, fd := range set.File {
+		if _, ok := seen[fd.GetName()]; ok {
+			continue
+		}
+		seen[fd.GetName()] = struct{}{}
+		ret.File = append(ret.File, fd)
+	}
+	for i := 0; i < len(ret.File); i++ {
+		for _, dep := range ret.File[i].GetDependency() {
+			if _, ok := seen[dep]; ok {
+				continue
+			}
+			f, err := protoregistry.GlobalFiles.FindFileByPath(dep)
+			if err != nil {
+				return nil, fmt.Errorf("缺少依赖 %s, 生成描述文件时需要 --include_imports", dep)
+			}
+			seen[dep] = struct{}{}
+			ret.File = append(ret.File, protodesc.ToFileDescriptorProto(f))
+		}
+	}
+	return protodesc.NewFiles(ret)
+}
+
+// 选择需要暴露的服务, names 为空表示所有服务
+func selectServices(files *protoregistry.Files, names []string) ([]protoreflect.ServiceDescriptor, error) {
+	ret := make([]protoreflect.ServiceDescriptor, 0)
+	if len(names) == 0 {
+		files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+			for i := 0; i < fd.Services().Len(); i++ {
+				ret = append(ret, fd.Services().Get(i))
+			}
+			return true
+		})
+		return ret, nil
+	}
+
+	for _, name := range names {
+		d, err := files.FindDescriptorByName(protoreflect.FullName(name))
+		if err != nil {
+			return nil, fmt.Errorf("proto描述中没有服务 %s", name)
+		}
+		sd, ok := d.(protoreflect.ServiceDescriptor)
+		if !ok {
+			return nil, fmt.Errorf("%s 不是服务", name)
+		}
+		ret = append(ret, sd)
+	}
+	return ret, nil
+}
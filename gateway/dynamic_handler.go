@@ -0,0 +1,196 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/utilities"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// 动态转码的方法
+type dynamicMethod struct {
+	serverName string
+	fullMethod string // 如 /hello.helloService/Say
+	pattern    string
+	desc       protoreflect.MethodDescriptor
+
+	bodyField protoreflect.FieldDescriptor // body 为具体字段时的字段
+	bodyAll   bool                         // body 为 *
+	rspField  protoreflect.FieldDescriptor // response_body 的字段
+}
+
+func newDynamicMethod(serverName string, md protoreflect.MethodDescriptor, rule *annotations.HttpRule, pattern string) (*dynamicMethod, error) {
+	m := &dynamicMethod{
+		serverName: serverName,
+		fullMethod: fmt.Sprintf("/%s/%s", md.Parent().FullName(), md.Name()),
+		pattern:    pattern,
+		desc:       md,
+	}
+	switch body := rule.GetBody(); body {
+	case "":
+	case "*":
+		m.bodyAll = true
+	default:
+		m.bodyField = md.Input().Fields().ByName(protoreflect.Name(body))
+		if m.bodyField == nil {
+			return nil, fmt.Errorf("方法 %s 的请求中没有 body 字段 %s", md.FullName(), body)
+		}
+	}
+	if rb := rule.GetResponseBody(); rb != "" {
+		m.rspField = md.Output().Fields().ByName(protoreflect.Name(rb))
+		if m.rspField == nil || m.rspField.Message() == nil || m.rspField.IsList() || m.rspField.IsMap() {
+			return nil, fmt.Errorf("方法 %s 的 response_body 必须是响应中的消息字段: %s", md.FullName(), rb)
+		}
+	}
+	return m, nil
+}
+
+func (g *Gateway) dynamicHandler(m *dynamicMethod) runtime.HandlerFunc {
+	if m.desc.IsStreamingServer() {
+		return g.dynamicStreamHandler(m)
+	}
+	mux := g.gwMux
+	return func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		inbound, outbound := runtime.MarshalerForRequest(mux, r)
+		ctx, err := runtime.AnnotateContext(ctx, mux, r, m.fullMethod, runtime.WithHTTPPathPattern(m.pattern))
+		if err != nil {
+			runtime.HTTPError(ctx, mux, outbound, w, r, err)
+			return
+		}
+
+		req, err := m.decodeRequest(inbound, r, pathParams)
+		if err != nil {
+			runtime.HTTPError(ctx, mux, outbound, w, r, err)
+			return
+		}
+
+		rsp := dynamicpb.NewMessage(m.desc.Output())
+		var md runtime.ServerMetadata
+		err = GetGatewayClientConn(m.serverName).Invoke(ctx, m.fullMethod, req, rsp, grpc.Header(&md.HeaderMD), grpc.Trailer(&md.TrailerMD))
+		ctx = runtime.NewServerMetadataContext(ctx, md)
+		if err != nil {
+			runtime.HTTPError(ctx, mux, outbound, w, r, err)
+			return
+		}
+
+		var out proto.Message = rsp
+		if m.rspField != nil {
+			out = rsp.Get(m.rspField).Message().Interface()
+		}
+		runtime.ForwardResponseMessage(ctx, mux, outbound, w, r, out, mux.GetForwardResponseOptions()...)
+	}
+}
+
+// 服务端流方法的处理器, 响应按 Accept 以 SSE, NDJSON 或 json 流返回
+func (g *Gateway) dynamicStreamHandler(m *dynamicMethod) runtime.HandlerFunc {
+	mux := g.gwMux
+	return func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		inbound, outbound := runtime.MarshalerForRequest(mux, r)
+		ctx, err := runtime.AnnotateContext(ctx, mux, r, m.fullMethod, runtime.WithHTTPPathPattern(m.pattern))
+		if err != nil {
+			runtime.HTTPError(ctx, mux, outbound, w, r, err)
+			return
+		}
+
+		req, err := m.decodeRequest(inbound, r, pathParams)
+		if err != nil {
+			runtime.HTTPError(ctx, mux, outbound, w, r, err)
+			return
+		}
+
+		stream, err := GetGatewayClientConn(m.serverName).NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, m.fullMethod)
+		if err == nil {
+			err = stream.SendMsg(req)
+		}
+		if err == nil {
+			err = stream.CloseSend()
+		}
+		var md runtime.ServerMetadata
+		if err == nil {
+			md.HeaderMD, err = stream.Header()
+		}
+		ctx = runtime.NewServerMetadataContext(ctx, md)
+		if err != nil {
+			runtime.HTTPError(ctx, mux, outbound, w, r, err)
+			return
+		}
+
+		runtime.ForwardResponseStream(ctx, mux, outbound, w, r, func() (proto.Message, error) {
+			rsp := dynamicpb.NewMessage(m.desc.Output())
+			if err := stream.RecvMsg(rsp); err != nil {
+				return nil, err
+			}
+			if m.rspField != nil {
+				return rsp.Get(m.rspField).Message().Interface(), nil
+			}
+			return rsp, nil
+		}, mux.GetForwardResponseOptions()...)
+	}
+}
+
+// 按 body, 路径参数, 查询参数的顺序构建请求
+func (m *dynamicMethod) decodeRequest(inbound runtime.Marshaler, r *http.Request, pathParams map[string]string) (proto.Message, error) {
+	req := dynamicpb.NewMessage(m.desc.Input())
+	switch {
+	case m.bodyAll:
+		if err := inbound.NewDecoder(r.Body).Decode(req); err != nil && err != io.EOF {
+			return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+		}
+	case m.bodyField != nil && m.bodyField.Message() != nil && !m.bodyField.IsList() && !m.bodyField.IsMap():
+		if err := inbound.NewDecoder(r.Body).Decode(req.Mutable(m.bodyField).Message().Interface()); err != nil && err != io.EOF {
+			return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+		}
+	case m.bodyField != nil:
+		// 非消息字段包装为 {"字段名": body} 后解析
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+		}
+		if len(bytes.TrimSpace(body)) > 0 {
+			name, _ := json.Marshal(string(m.bodyField.Name()))
+			data := bytes.Join([][]byte{[]byte("{"), name, []byte(":"), body, []byte("}")}, nil)
+			if err = (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, req); err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+			}
+		}
+	}
+
+	filter := make([][]string, 0, len(pathParams)+1)
+	for k, v := range pathParams {
+		if err := runtime.PopulateFieldFromPath(req, k, v); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "解析路径参数 %s 失败: %v", k, err)
+		}
+		filter = append(filter, strings.Split(k, "."))
+	}
+	if m.bodyAll {
+		return req, nil
+	}
+	if m.bodyField != nil {
+		filter = append(filter, []string{string(m.bodyField.Name())})
+	}
+	if err := r.ParseForm(); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+	if err := runtime.PopulateQueryParameters(req, r.Form, utilities.NewDoubleArray(filter)); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+	return req, nil
+}
@@ -0,0 +1,146 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// 测试用的方法描述
+//
+//	message Sub { string a = 1; }
+//	message Item { string id = 1; string name = 2; Sub sub = 3; int32 page = 4; }
+//	service ItemService { rpc Get(Item) returns (Item); }
+func newTestMethodDesc(t *testing.T) protoreflect.MethodDescriptor {
+	field := func(name string, num int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string) *descriptorpb.FieldDescriptorProto {
+		f := &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			Number:   proto.Int32(num),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     typ.Enum(),
+			JsonName: proto.String(name),
+		}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+	fd := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("test/item.proto"),
+		Package: proto.String("test"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("Sub"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("a", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+			}},
+			{Name: proto.String("Item"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("id", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+				field("name", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+				field("sub", 3, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".test.Sub"),
+				field("page", 4, descriptorpb.FieldDescriptorProto_TYPE_INT32, ""),
+			}},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("ItemService"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:       proto.String("Get"),
+				InputType:  proto.String(".test.Item"),
+				OutputType: proto.String(".test.Item"),
+			}},
+		}},
+	}
+	f, err := protodesc.NewFile(fd, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f.Services().Get(0).Methods().Get(0)
+}
+
+func TestDynamicDecodeRequest(t *testing.T) {
+	md := newTestMethodDesc(t)
+	tests := []struct {
+		name       string
+		body       string // google.api.http 的 body
+		reqBody    string
+		query      string
+		pathParams map[string]string
+		want       string
+	}{
+		{
+			name:       "body为*时忽略查询参数, 路径参数优先",
+			body:       "*",
+			reqBody:    `{"id":"b","name":"n","sub":{"a":"x"}}`,
+			query:      "page=2",
+			pathParams: map[string]string{"id": "1"},
+			want:       `{"id":"1","name":"n","sub":{"a":"x"}}`,
+		},
+		{
+			name:       "body为消息字段时合并路径和查询参数",
+			body:       "sub",
+			reqBody:    `{"a":"x"}`,
+			query:      "name=n&page=2&sub.a=y",
+			pathParams: map[string]string{"id": "1"},
+			want:       `{"id":"1","name":"n","sub":{"a":"x"},"page":2}`,
+		},
+		{
+			name:    "body为非消息字段",
+			body:    "name",
+			reqBody: `"n"`,
+			query:   "page=3&name=q",
+			want:    `{"name":"n","page":3}`,
+		},
+		{
+			name:       "没有body",
+			query:      "name=q&sub.a=z",
+			pathParams: map[string]string{"id": "1"},
+			want:       `{"id":"1","name":"q","sub":{"a":"z"}}`,
+		},
+		{
+			name:       "嵌套的路径参数",
+			body:       "*",
+			reqBody:    `{}`,
+			pathParams: map[string]string{"sub.a": "p"},
+			want:       `{"sub":{"a":"p"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := newDynamicMethod("test", md, &annotations.HttpRule{Body: tt.body}, "/test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := httptest.NewRequest(http.MethodPost, "/test?"+tt.query, strings.NewReader(tt.reqBody))
+			req, err := m.decodeRequest(&runtime.JSONPb{}, r, tt.pathParams)
+			if err != nil {
+				t.Fatalf("解析请求失败: %v", err)
+			}
+			want := req.ProtoReflect().New().Interface()
+			if err = protojson.Unmarshal([]byte(tt.want), want); err != nil {
+				t.Fatal(err)
+			}
+			if !proto.Equal(req, want) {
+				t.Fatalf("请求为 %v, 期望 %s", req, tt.want)
+			}
+		})
+	}
+
+	// body 字段不存在
+	if _, err := newDynamicMethod("test", md, &annotations.HttpRule{Body: "none"}, "/test"); err == nil {
+		t.Fatal("body 字段不存在时应返回错误")
+	}
+	// 无效的路径参数
+	m, _ := newDynamicMethod("test", md, &annotations.HttpRule{}, "/test")
+	r := httptest.NewRequest(http.MethodGet, "/test", nil)
+	if _, err := m.decodeRequest(&runtime.JSONPb{}, r, map[string]string{"page": "x"}); err == nil {
+		t.Fatal("无效的路径参数应返回错误")
+	}
+}
@@ -0,0 +1,90 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// 通过grpc服务端反射获取服务及其依赖的proto描述, services 为空表示所有服务
+func fetchDescriptorSetByReflection(ctx context.Context, cc grpc.ClientConnInterface, services []string) (*descriptorpb.FileDescriptorSet, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // 结束流
+
+	stream, err := rpb.NewServerReflectionClient(cc).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	call := func(req *rpb.ServerReflectionRequest) (*rpb.ServerReflectionResponse, error) {
+		if err := stream.Send(req); err != nil {
+			return nil, err
+		}
+		rsp, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		if e := rsp.GetErrorResponse(); e != nil {
+			return nil, fmt.Errorf("code=%d, message=%s", e.GetErrorCode(), e.GetErrorMessage())
+		}
+		return rsp, nil
+	}
+
+	if len(services) == 0 {
+		rsp, err := call(&rpb.ServerReflectionRequest{MessageRequest: &rpb.ServerReflectionRequest_ListServices{}})
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range rsp.GetListServicesResponse().GetService() {
+			if !strings.HasPrefix(s.GetName(), "grpc.reflection.") {
+				services = append(services, s.GetName())
+			}
+		}
+	}
+
+	files := make(map[string]*descriptorpb.FileDescriptorProto)
+	set := &descriptorpb.FileDescriptorSet{}
+	addFiles := func(rsp *rpb.ServerReflectionResponse) error {
+		for _, bs := range rsp.GetFileDescriptorResponse().GetFileDescriptorProto() {
+			fd := &descriptorpb.FileDescriptorProto{}
+			if err := proto.Unmarshal(bs, fd); err != nil {
+				return err
+			}
+			if _, ok := files[fd.GetName()]; !ok {
+				files[fd.GetName()] = fd
+				set.File = append(set.File, fd)
+			}
+		}
+		return nil
+	}
+
+	for _, s := range services {
+		rsp, err := call(&rpb.ServerReflectionRequest{MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: s}})
+		if err != nil {
+			return nil, fmt.Errorf("获取服务 %s 失败: %v", s, err)
+		}
+		if err = addFiles(rsp); err != nil {
+			return nil, err
+		}
+	}
+	// 服务端可能不会一次返回所有依赖
+	for i := 0; i < len(set.File); i++ {
+		for _, dep := range set.File[i].GetDependency() {
+			if _, ok := files[dep]; ok {
+				continue
+			}
+			rsp, err := call(&rpb.ServerReflectionRequest{MessageRequest: &rpb.ServerReflectionRequest_FileByFilename{FileByFilename: dep}})
+			if err != nil {
+				return nil, fmt.Errorf("获取依赖 %s 失败: %v", dep, err)
+			}
+			if err = addFiles(rsp); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return set, nil
+}
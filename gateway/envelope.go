@@ -0,0 +1,102 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"text/template"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/zly-app/grpc/pkg"
+)
+
+const (
+	// 包装为 Response
+	EnvelopeWrapped = "wrapped"
+	// 直接返回proto的json, 错误返回grpc状态的json
+	EnvelopeRaw = "raw"
+	// 使用自定义模板
+	EnvelopeTemplate = "template"
+)
+
+// 模板中序列化proto消息的选项, 和网关的json编码一致
+var envelopeMarshalOptions = protojson.MarshalOptions{EmitUnpopulated: true, UseProtoNames: true}
+
+func checkEnvelope(envelope string) error {
+	switch envelope {
+	case "", EnvelopeWrapped, EnvelopeRaw, EnvelopeTemplate:
+		return nil
+	}
+	return fmt.Errorf("无效的响应格式: %s, 支持 wrapped, raw, template", envelope)
+}
+
+func parseEnvelopeTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		return nil, fmt.Errorf("使用 template 响应格式需要设置 EnvelopeTemplate")
+	}
+	t, err := template.New("envelope").Funcs(template.FuncMap{"json": envelopeJSON}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("解析 EnvelopeTemplate 失败: %v", err)
+	}
+	return t, nil
+}
+
+// 模板中的 json 函数, proto消息使用protojson序列化
+func envelopeJSON(v interface{}) (string, error) {
+	var bs []byte
+	var err error
+	if m, ok := v.(proto.Message); ok {
+		bs, err = envelopeMarshalOptions.Marshal(m)
+	} else {
+		bs, err = json.Marshal(v)
+	}
+	return string(bs), err
+}
+
+// 获取请求的响应格式, 路由配置优先
+func (conf *ServerConfig) envelope(ctx context.Context) string {
+	if r := conf.ctxRouteConfig(ctx); r != nil && r.Envelope != "" {
+		return r.Envelope
+	}
+	return conf.Envelope
+}
+
+// 按响应格式改写响应, 包装后的响应总是会被记录, 用于过滤器日志
+func (g *Gateway) forwardResponseRewriter(ctx context.Context, response proto.Message) (any, error) {
+	ret, err := ForwardResponseRewriter(ctx, response)
+	if err != nil {
+		return nil, err
+	}
+	switch g.conf.envelope(ctx) {
+	case EnvelopeRaw:
+		return response, nil
+	case EnvelopeTemplate:
+		var buf bytes.Buffer
+		if err = g.conf.envelopeTemplate.Execute(&buf, ret); err != nil {
+			return nil, fmt.Errorf("执行响应模板失败: %v", err)
+		}
+		if !json.Valid(buf.Bytes()) {
+			return nil, fmt.Errorf("响应模板的结果不是有效的json: %s", buf.String())
+		}
+		return json.RawMessage(buf.Bytes()), nil
+	}
+	return ret, nil
+}
+
+// 错误处理, 按配置的错误码映射http状态码, 业务错误码设置的http状态码优先
+func (g *Gateway) errorHandler(ctx context.Context, mux *runtime.ServeMux, marshaler runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+	var he *runtime.HTTPStatusError
+	if b, ok := pkg.GetBizCodeByErr(err); (!ok || b.HttpStatus <= 0) && !errors.As(err, &he) {
+		if st, ok := g.conf.httpStatus[status.Code(err)]; ok {
+			err = &runtime.HTTPStatusError{HTTPStatus: st, Err: err}
+		}
+	}
+	ErrorHandler(ctx, mux, marshaler, w, r, err)
+}
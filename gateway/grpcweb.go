@@ -0,0 +1,187 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// gRPC-Web 数据帧
+	grpcWebFrameData byte = 0x00
+	// gRPC-Web 压缩标记
+	grpcWebFrameCompressed byte = 0x01
+	// gRPC-Web 尾部帧
+	grpcWebFrameTrailer byte = 0x80
+)
+
+// 处理 gRPC-Web 请求, 支持一元方法和服务端流方法
+func (g *Gateway) serveGrpcWeb(w http.ResponseWriter, r *http.Request) {
+	ct := r.Header.Get("Content-Type")
+	gw := &grpcWebWriter{w: w, text: strings.HasPrefix(ct, "application/grpc-web-text")}
+	w.Header().Set("Content-Type", ct)
+
+	req, err := gw.readRequest(r.Body)
+	if err != nil {
+		gw.finish(nil, err)
+		return
+	}
+	fullMethod := r.URL.Path
+	service, err := parseWebMethod(fullMethod)
+	if err != nil {
+		gw.finish(nil, err)
+		return
+	}
+	// 找不到方法描述时使用流调用, 同时兼容一元方法和服务端流方法
+	serverStream := true
+	if md := g.findMethod(fullMethod); md != nil {
+		if md.IsStreamingClient() {
+			gw.finish(nil, status.Error(codes.Unimplemented, "gRPC-Web 不支持客户端流"))
+			return
+		}
+		serverStream = md.IsStreamingServer()
+	}
+
+	ctx, err := g.webContext(r.Context(), r, fullMethod)
+	if err != nil {
+		gw.finish(nil, err)
+		return
+	}
+	trailer, err := webInvoke(ctx, GetGatewayClientConn(g.webServerName(service)), fullMethod, serverStream, req, gw.writeHeader, gw.writeMessage)
+	gw.finish(trailer, err)
+}
+
+type grpcWebWriter struct {
+	w           http.ResponseWriter
+	text        bool // grpc-web-text, 数据使用base64编码
+	wroteHeader bool
+}
+
+// 读取请求中的第一个消息
+func (gw *grpcWebWriter) readRequest(body io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "读取请求失败: %v", err)
+	}
+	if gw.text {
+		if data, err = decodeBase64Chunks(data); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "解析base64请求失败: %v", err)
+		}
+	}
+	if len(data) < 5 {
+		return nil, status.Error(codes.InvalidArgument, "请求缺少消息")
+	}
+	if data[0]&grpcWebFrameCompressed != 0 {
+		return nil, status.Error(codes.Unimplemented, "不支持压缩的消息")
+	}
+	n := binary.BigEndian.Uint32(data[1:5])
+	if uint32(len(data)-5) < n {
+		return nil, status.Error(codes.InvalidArgument, "请求消息不完整")
+	}
+	return data[5 : 5+n], nil
+}
+
+func (gw *grpcWebWriter) writeHeader(md metadata.MD) {
+	if gw.wroteHeader {
+		return
+	}
+	gw.wroteHeader = true
+	writeMetadataHeader(gw.w.Header(), md, "")
+	gw.w.WriteHeader(http.StatusOK)
+}
+
+func (gw *grpcWebWriter) writeMessage(data []byte) error {
+	return gw.writeFrame(grpcWebFrameData, data)
+}
+
+func (gw *grpcWebWriter) writeFrame(flag byte, data []byte) error {
+	frame := make([]byte, 5+len(data))
+	frame[0] = flag
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(data)))
+	copy(frame[5:], data)
+	if gw.text {
+		frame = []byte(base64.StdEncoding.EncodeToString(frame))
+	}
+	if _, err := gw.w.Write(frame); err != nil {
+		return err
+	}
+	if f, ok := gw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// 写入尾部帧, 包含状态及尾部元数据
+func (gw *grpcWebWriter) finish(trailer metadata.MD, err error) {
+	gw.writeHeader(nil)
+
+	s := status.Convert(err)
+	var buf bytes.Buffer
+	buf.WriteString("grpc-status: " + strconv.Itoa(int(s.Code())) + "\r\n")
+	if s.Message() != "" {
+		buf.WriteString("grpc-message: " + encodeGrpcMessage(s.Message()) + "\r\n")
+	}
+	if len(s.Proto().GetDetails()) > 0 {
+		if bs, err := proto.Marshal(s.Proto()); err == nil {
+			buf.WriteString("grpc-status-details-bin: " + base64.StdEncoding.EncodeToString(bs) + "\r\n")
+		}
+	}
+	h := http.Header{}
+	writeMetadataHeader(h, trailer, "")
+	for k, vs := range h {
+		k = strings.ToLower(k)
+		if strings.HasPrefix(k, "grpc-") {
+			continue
+		}
+		for _, v := range vs {
+			buf.WriteString(k + ": " + v + "\r\n")
+		}
+	}
+	_ = gw.writeFrame(grpcWebFrameTrailer, buf.Bytes())
+}
+
+// 按grpc协议对状态消息进行百分号编码
+func encodeGrpcMessage(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c >= ' ' && c <= '~' && c != '%' {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteString(fmt.Sprintf("%%%02X", c))
+	}
+	return b.String()
+}
+
+// 解码 grpc-web-text 请求, 客户端可能分多段编码, 每段都有填充
+func decodeBase64Chunks(data []byte) ([]byte, error) {
+	data = bytes.TrimSpace(data)
+	ret := make([]byte, 0, base64.StdEncoding.DecodedLen(len(data)))
+	for len(data) > 0 {
+		end := len(data)
+		if k := bytes.IndexByte(data, '='); k != -1 {
+			end = k
+			for end < len(data) && data[end] == '=' {
+				end++
+			}
+		}
+		bs, err := decodeBase64(string(data[:end]))
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, bs...)
+		data = data[end:]
+	}
+	return ret, nil
+}
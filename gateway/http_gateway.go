@@ -7,27 +7,41 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"sync"
 	"time"
+	"unsafe"
 
-	"github.com/bytedance/sonic"
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"github.com/zly-app/zapp/core"
 	"github.com/zly-app/zapp/filter"
 	"github.com/zly-app/zapp/handler"
-	"github.com/zly-app/zapp/pkg/utils"
+	"go.opentelemetry.io/otel/propagation"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/encoding/protojson"
 
 	"github.com/zly-app/grpc/pkg"
 )
 
+const (
+	// 通过反射加载动态转码失败时的重试间隔
+	reflectionRetryMinInterval = 200 * time.Millisecond
+	reflectionRetryMaxInterval = 5 * time.Second
+)
+
 type Gateway struct {
 	app          core.IApp
+	conf         *ServerConfig
 	bind         string // 网关bind
 	gwMux        *runtime.ServeMux
 	closeWaitSec int
 	httpHandler  http.Handler
+	cors         *cors
+
+	dynamicServices sync.Map // 服务全名 -> 服务名
+	dynamicMethods  sync.Map // 方法全名 -> protoreflect.MethodDescriptor
 }
 
 func NewGateway(app core.IApp, conf *ServerConfig) (*Gateway, error) {
@@ -35,55 +49,101 @@ func NewGateway(app core.IApp, conf *ServerConfig) (*Gateway, error) {
 		return nil, fmt.Errorf("Grpc网关配置检查失败: %v", err)
 	}
 
-	var mar runtime.Marshaler = &runtime.HTTPBodyMarshaler{
-		Marshaler: &runtime.JSONPb{
-			MarshalOptions: protojson.MarshalOptions{
-				EmitUnpopulated: true,
-				UseProtoNames:   true,
-			},
-			UnmarshalOptions: protojson.UnmarshalOptions{
-				DiscardUnknown: true,
-			},
+	jsonMar := &runtime.JSONPb{
+		MarshalOptions: protojson.MarshalOptions{
+			EmitUnpopulated: true,
+			UseProtoNames:   true,
+		},
+		UnmarshalOptions: protojson.UnmarshalOptions{
+			DiscardUnknown: true,
 		},
 	}
-	gwMux := runtime.NewServeMux(
-		runtime.WithMetadata(gatewayMetadataAnnotator),
-		runtime.WithMarshalerOption(runtime.MIMEWildcard, mar),
-		runtime.WithForwardResponseRewriter(ForwardResponseRewriter),
-	)
-	var httpHandler http.Handler = gwMux
-	if conf.CorsAllowAll {
-		httpHandler = allowCORS(gwMux)
+	propagator, err := pkg.NewPropagator(conf.TracePropagators)
+	if err != nil {
+		return nil, err
 	}
-	return &Gateway{
+	g := &Gateway{
 		app:          app,
+		conf:         conf,
 		bind:         conf.Bind,
-		gwMux:        gwMux,
 		closeWaitSec: conf.CloseWait,
-		httpHandler:  reqFilter(app.Name(), httpHandler),
-	}, nil
+	}
+	var mar runtime.Marshaler = &runtime.HTTPBodyMarshaler{Marshaler: jsonMar}
+	gwMux := runtime.NewServeMux(
+		runtime.WithMetadata(g.metadataAnnotator),
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, mar),
+		runtime.WithMarshalerOption(MIMEEventStream, &streamMarshaler{Marshaler: jsonMar, sse: true}),
+		runtime.WithMarshalerOption(MIMENdjson, &streamMarshaler{Marshaler: jsonMar}),
+		runtime.WithForwardResponseRewriter(g.forwardResponseRewriter),
+		runtime.WithErrorHandler(g.errorHandler),
+		runtime.WithStreamErrorHandler(StreamErrorHandler),
+	)
+	g.gwMux = gwMux
+	httpHandler := g.webHandler(gwMux)
+	if g.cors = newCors(conf); g.cors != nil {
+		httpHandler = g.cors.handler(httpHandler)
+	}
+	g.httpHandler = g.reqFilter(propagator, httpHandler)
+
+	// 通过反射获取描述的动态转码需要服务端已启动, 在网关启动时加载
+	for _, d := range conf.Dynamic {
+		if d != nil && !d.Reflection {
+			if err = g.RegisterDynamic(context.Background(), d); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return g, nil
 }
 
-func (g *Gateway) GetMux() *runtime.ServeMux {
-	return g.gwMux
+// 加载通过反射获取描述的动态转码
+func (g *Gateway) LoadReflectionDynamic(ctx context.Context) error {
+	for _, d := range g.conf.Dynamic {
+		if d != nil && d.Reflection {
+			if err := g.RegisterDynamic(ctx, d); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
-func allowCORS(h http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		method := r.Method
-		origin := r.Header.Get("Origin")
-		if origin != "" {
-			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Access-Control-Allow-Methods", "HEAD, GET, POST, PUT, PATCH, DELETE")
-			w.Header().Set("Access-Control-Allow-Headers", "*")
-			w.Header().Set("Access-Control-Allow-Credentials", "true")
+// 加载通过反射获取描述的动态转码, 失败时按退避间隔重试, 直到超过 ReflectionWait
+func (g *Gateway) loadReflectionDynamicWithRetry(ctx context.Context) error {
+	deadline := time.Now().Add(time.Duration(g.conf.ReflectionWait) * time.Second)
+	for _, d := range g.conf.Dynamic {
+		if d == nil || !d.Reflection {
+			continue
 		}
-		if method == "OPTIONS" {
-			w.WriteHeader(http.StatusNoContent)
-			return
+		interval := reflectionRetryMinInterval
+		for {
+			err := g.RegisterDynamic(ctx, d)
+			if err == nil {
+				break
+			}
+			if time.Now().Add(interval).After(deadline) {
+				return err
+			}
+			g.app.Warn("grpc网关加载动态转码失败, 稍后重试", zap.String("serverName", d.ServerName),
+				zap.Duration("interval", interval), zap.Error(err))
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(interval):
+			}
+			interval = min(interval*2, reflectionRetryMaxInterval)
 		}
-		h.ServeHTTP(w, r)
-	})
+	}
+	return nil
+}
+
+func (g *Gateway) GetMux() *runtime.ServeMux {
+	return g.gwMux
+}
+
+// 获取网关http处理器, 包含请求过滤, 链路追踪及跨域处理
+func (g *Gateway) Handler() http.Handler {
+	return g.httpHandler
 }
 
 type filterRsp struct {
@@ -91,22 +151,43 @@ type filterRsp struct {
 	Response *Response
 }
 
-func reqFilter(appName string, h http.Handler) http.Handler {
+func (g *Gateway) reqFilter(propagator propagation.TextMapPropagator, h http.Handler) http.Handler {
+	stream := g.conf.Stream
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		body, _ := io.ReadAll(r.Body)
-		_ = r.Body.Close()
+		reqCtx := r.Context()
+
+		// 请求id, 上游没有传入时生成一个新的
+		requestId := r.Header.Get(pkg.RequestIdHeader)
+		if !pkg.IsValidRequestId(requestId) {
+			requestId = pkg.NewRequestId()
+			r.Header.Set(pkg.RequestIdHeader, requestId)
+		}
+		w.Header().Set(pkg.RequestIdHeader, requestId)
+
+		route, _ := g.conf.MatchRouteConfig(r.URL.Path)
+		body, err := readBody(r, g.conf.maxBodySize(route))
+		if err != nil {
+			g.httpError(w, r.WithContext(pkg.SaveRequestId(reqCtx, requestId)), err)
+			return
+		}
 		r.Body = io.NopCloser(bytes.NewReader(body))
 
 		d := &pkg.GatewayData{
-			Method:   r.Method,
-			Path:     r.URL.Path,
-			RawQuery: r.URL.RawQuery,
-			RawBody:  string(body),
-			IP:       RequestExtractIP(r),
-			Headers:  r.Header,
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			RawQuery:  r.URL.RawQuery,
+			RawBody:   unsafe.String(unsafe.SliceData(body), len(body)), // body 不会被修改, 避免复制
+			IP:        RequestExtractIP(r),
+			Headers:   r.Header,
+			RequestId: requestId,
 		}
-		ctx := pkg.SaveGatewayData(r.Context(), d)              // 存入网关数据
-		ctx, _ = utils.Trace.GetSpanWithHeaders(ctx, d.Headers) // 根据header中的trace构造
+		ctx := pkg.SaveGatewayData(r.Context(), d) // 存入网关数据
+		ctx = pkg.SaveRequestId(ctx, requestId)
+		ctx = extractTraceFromHeader(ctx, propagator, d.Headers) // 根据header中的trace构造
+		ctx, span := startHttpSpan(ctx, r, d.IP)                 // http服务端span
+		sw := &statusWriter{ResponseWriter: w}
+		defer func() { endHttpSpan(span, sw.Status()) }()
+		w = sw
 		// 从headers中获取主调信息
 		callMeta := filter.GetCallerMetaByHeader(r.Header)
 		ctx = filter.SaveCallerMeta(ctx, filter.CallerMeta{
@@ -123,6 +204,16 @@ func reqFilter(appName string, h http.Handler) http.Handler {
 		_, _ = chain.Handle(ctx, d, func(ctx context.Context, req interface{}) (interface{}, error) {
 			ctx = filter.SaveCallerMeta(ctx, filter.CallerMeta{}) // 将上游携带的主调信息置空
 			ctx = initResponseStorage(ctx)                        // 用于储存response
+			if isStreamRequest(r) {
+				var cancel context.CancelFunc
+				ctx, cancel = streamContext(ctx, reqCtx, stream.Timeout)
+				defer cancel()
+				if isSSERequest(r) && stream.Keepalive > 0 {
+					sw := newStreamWriter(w)
+					defer sw.keepalive(time.Duration(stream.Keepalive) * time.Second)()
+					w = sw
+				}
+			}
 			r = r.WithContext(ctx)
 			h.ServeHTTP(w, r)
 			sp := &filterRsp{
@@ -135,6 +226,12 @@ func reqFilter(appName string, h http.Handler) http.Handler {
 }
 
 func (g *Gateway) StartGateway() error {
+	// 服务端可能还未启动, 失败时重试. 路由需要在开始处理请求前注册完成
+	if err := g.loadReflectionDynamicWithRetry(g.app.BaseContext()); err != nil {
+		g.app.Error("grpc网关加载动态转码失败", zap.Error(err))
+		return err
+	}
+
 	listener, err := net.Listen("tcp", g.bind)
 	if err != nil {
 		return err
@@ -155,11 +252,48 @@ func (g *Gateway) StartGateway() error {
 }
 
 // grpc元数据注解器
-func gatewayMetadataAnnotator(ctx context.Context, req *http.Request) metadata.MD {
+func (g *Gateway) metadataAnnotator(ctx context.Context, req *http.Request) metadata.MD {
+	setHttpSpanRoute(ctx, req)
 	d := pkg.GetGatewayData(ctx)
 	if d != nil {
-		s, _ := sonic.MarshalString(d)
-		return metadata.MD{pkg.GatewayMDataKey: []string{s}}
+		// 按路由配置去掉不需要转发的数据
+		rawBody, headers := g.conf.forwardOptions(g.conf.requestRouteConfig(ctx, req))
+		if !rawBody || !headers {
+			v := *d
+			if !rawBody {
+				v.RawBody = ""
+			}
+			if !headers {
+				v.Headers = nil
+			}
+			d = &v
+		}
+		bs := pkg.MarshalGatewayData(d)
+		s := unsafe.String(unsafe.SliceData(bs), len(bs)) // bs 不会被修改, 避免复制
+		return metadata.MD{pkg.GatewayMDataKey: []string{s}, pkg.RequestIdMDataKey: []string{d.RequestId}}
 	}
 	return nil
 }
+
+// 读取请求body, 超过 maxSize 时返回413错误, maxSize 小于0表示不限制
+func readBody(r *http.Request, maxSize int64) ([]byte, error) {
+	defer r.Body.Close()
+	if maxSize < 0 {
+		return io.ReadAll(r.Body)
+	}
+	tooLarge := &runtime.HTTPStatusError{
+		HTTPStatus: http.StatusRequestEntityTooLarge,
+		Err:        status.Errorf(codes.ResourceExhausted, "请求body超过限制 %d 字节", maxSize),
+	}
+	if r.ContentLength > maxSize {
+		return nil, tooLarge
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxSize+1))
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "读取请求body失败: %v", err)
+	}
+	if int64(len(body)) > maxSize {
+		return nil, tooLarge
+	}
+	return body, nil
+}
@@ -2,33 +2,109 @@ package gateway
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
 
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"github.com/zly-app/zapp/pkg/utils"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/zly-app/grpc/pkg"
 )
 
 type Response struct {
-	Code    int32       `json:"code"`
-	Message string      `json:"message,omitempty"`
-	Data    interface{} `json:"data,omitempty"`
-	TraceId string      `json:"trace_id,omitempty"`
+	Code      int32            `json:"code"`
+	Message   string           `json:"message,omitempty"`
+	Errors    []*ResponseError `json:"errors,omitempty"`
+	Data      interface{}      `json:"data,omitempty"`
+	TraceId   string           `json:"trace_id,omitempty"`
+	RequestId string           `json:"request_id,omitempty"`
+}
+
+// 字段错误
+type ResponseError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
 }
 
 func ForwardResponseRewriter(ctx context.Context, response proto.Message) (any, error) {
 	var ret *Response
 
 	traceId, _ := utils.Trace.GetOTELTraceID(ctx)
+	requestId := pkg.GetRequestId(ctx)
 	s, ok := response.(*spb.Status)
 	if ok {
-		ret = &Response{Code: s.GetCode(), Message: s.GetMessage(), TraceId: traceId}
+		ret = statusResponse(s)
 	} else {
-		ret = &Response{Data: response, TraceId: traceId}
+		ret = &Response{Data: responseData(response)}
 	}
+	ret.TraceId, ret.RequestId = traceId, requestId
 	saveResponse(ctx, ret)
 	return ret, nil
 }
 
+// 将错误状态转为响应
+func statusResponse(s *spb.Status) *Response {
+	ret := &Response{Code: s.GetCode(), Message: s.GetMessage(), Errors: extractResponseErrors(s)}
+	err := status.FromProto(s).Err()
+	if b, ok := pkg.GetBizCodeByErr(err); ok { // 业务错误码
+		ret.Code = b.Code
+	}
+	if lm, ok := pkg.GetLocalizedMessage(err); ok && lm.GetMessage() != "" { // 本地化消息
+		ret.Message = lm.GetMessage()
+	}
+	return ret
+}
+
+// 动态转码的响应没有json标签, 按生成代码的字段名序列化
+var dynamicMarshalOptions = protojson.MarshalOptions{UseProtoNames: true}
+
+func responseData(response proto.Message) interface{} {
+	if _, ok := response.(*dynamicpb.Message); !ok {
+		return response
+	}
+	bs, err := dynamicMarshalOptions.Marshal(response)
+	if err != nil {
+		return response
+	}
+	return json.RawMessage(bs)
+}
+
+// 错误处理, 业务错误使用其映射的http状态码
+func ErrorHandler(ctx context.Context, mux *runtime.ServeMux, marshaler runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+	if b, ok := pkg.GetBizCodeByErr(err); ok && b.HttpStatus > 0 {
+		err = &runtime.HTTPStatusError{HTTPStatus: b.HttpStatus, Err: err}
+	}
+	runtime.DefaultHTTPErrorHandler(ctx, mux, marshaler, w, r, err)
+}
+
+// 流错误处理, 记录错误响应
+func StreamErrorHandler(ctx context.Context, err error) *status.Status {
+	s := status.Convert(err)
+	_, _ = ForwardResponseRewriter(ctx, s.Proto())
+	return s
+}
+
+// 从错误详情中提取字段错误
+func extractResponseErrors(s *spb.Status) []*ResponseError {
+	var ret []*ResponseError
+	for _, d := range s.GetDetails() {
+		br := &errdetails.BadRequest{}
+		if !d.MessageIs(br) || d.UnmarshalTo(br) != nil {
+			continue
+		}
+		for _, v := range br.GetFieldViolations() {
+			ret = append(ret, &ResponseError{Field: v.GetField(), Reason: v.GetDescription()})
+		}
+	}
+	return ret
+}
+
 type responseStorageFlag struct{}
 
 func initResponseStorage(ctx context.Context) context.Context {
@@ -0,0 +1,130 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+
+	"github.com/zly-app/grpc/pkg"
+)
+
+// 将带参数的路由模板转为正则, 不带参数时返回 nil. {name} 和 * 匹配一段路径, {name=a/*} 按等号后的模板匹配, ** 匹配任意路径
+func routePathRegexp(pattern string) (*regexp.Regexp, error) {
+	if !strings.ContainsAny(pattern, "{*") {
+		return nil, nil
+	}
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); {
+		if pattern[i] == '{' {
+			end := strings.IndexByte(pattern[i:], '}')
+			if end == -1 {
+				return nil, fmt.Errorf("路由模板缺少 }")
+			}
+			sub := "*"
+			if k := strings.IndexByte(pattern[i+1:i+end], '='); k != -1 {
+				sub = pattern[i+1+k+1 : i+end]
+			}
+			writeRouteSegments(&b, sub)
+			i += end + 1
+			continue
+		}
+		j := strings.IndexByte(pattern[i:], '{')
+		if j == -1 {
+			j = len(pattern) - i
+		}
+		writeRouteSegments(&b, pattern[i:i+j])
+		i += j
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+func writeRouteSegments(b *strings.Builder, s string) {
+	for k, seg := range strings.Split(s, "/") {
+		if k > 0 {
+			b.WriteString("/")
+		}
+		switch seg {
+		case "*":
+			b.WriteString("[^/]+")
+		case "**":
+			b.WriteString(".*")
+		default:
+			b.WriteString(regexp.QuoteMeta(seg))
+		}
+	}
+}
+
+// 获取请求路径的路由配置, 先按路由精确匹配, 再匹配带参数的路由
+func (conf *ServerConfig) MatchRouteConfig(path string) (*RouteConfig, bool) {
+	if b, ok := conf.routeMap[path]; ok {
+		return b, true
+	}
+	for _, b := range conf.routeRegexp {
+		if b.pathRegexp.MatchString(path) {
+			return b, true
+		}
+	}
+	return nil, false
+}
+
+// 获取请求的路由配置, 优先使用网关匹配到的路由模板
+func (conf *ServerConfig) requestRouteConfig(ctx context.Context, r *http.Request) *RouteConfig {
+	return conf.routeConfig(ctx, r.URL.Path)
+}
+
+// 获取ctx中请求的路由配置, 请求路径从网关数据中获取
+func (conf *ServerConfig) ctxRouteConfig(ctx context.Context) *RouteConfig {
+	path := ""
+	if d := pkg.GetGatewayData(ctx); d != nil {
+		path = d.Path
+	}
+	return conf.routeConfig(ctx, path)
+}
+
+/*
+获取路由配置.
+
+	先按网关匹配到的路由模板精确匹配, 再按请求路径匹配. 配置的路由模板和proto中的写法不同,
+	或 gRPC-Web, Connect 和 WebSocket 请求 (路由模板为请求路径) 时需要按请求路径匹配
+*/
+func (conf *ServerConfig) routeConfig(ctx context.Context, path string) *RouteConfig {
+	if pattern, ok := runtime.HTTPPathPattern(ctx); ok {
+		if b, ok := conf.GetRouteConfig(pattern); ok {
+			return b
+		}
+	}
+	if path == "" {
+		return nil
+	}
+	b, _ := conf.MatchRouteConfig(path)
+	return b
+}
+
+// 获取请求body的最大字节数, 小于0表示不限制
+func (conf *ServerConfig) maxBodySize(route *RouteConfig) int64 {
+	if route != nil && route.MaxBodySize != 0 {
+		return route.MaxBodySize
+	}
+	return conf.MaxBodySize
+}
+
+// 获取转发的网关数据中是否携带 RawBody 和 Headers
+func (conf *ServerConfig) forwardOptions(route *RouteConfig) (rawBody, headers bool) {
+	rawBody, headers = conf.ForwardRawBody, conf.ForwardHeaders
+	if route == nil {
+		return
+	}
+	if route.ForwardRawBody != nil {
+		rawBody = *route.ForwardRawBody
+	}
+	if route.ForwardHeaders != nil {
+		headers = *route.ForwardHeaders
+	}
+	return
+}
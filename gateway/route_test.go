@@ -0,0 +1,166 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+
+	"github.com/zly-app/grpc/pkg"
+)
+
+func TestRoutePathRegexp(t *testing.T) {
+	tests := []struct {
+		pattern string
+		plain   bool // 不带参数
+		match   []string
+		noMatch []string
+	}{
+		{pattern: "/hello/say", plain: true},
+		{pattern: "/v1/items/{id}", match: []string{"/v1/items/1", "/v1/items/a.b"}, noMatch: []string{"/v1/items", "/v1/items/1/x", "/v1/items/"}},
+		{pattern: "/v1/*/items", match: []string{"/v1/a/items"}, noMatch: []string{"/v1/a/b/items"}},
+		{pattern: "/v1/{name=shelves/*}/books", match: []string{"/v1/shelves/1/books"}, noMatch: []string{"/v1/shelves/books", "/v1/other/1/books"}},
+		{pattern: "/v1/files/**", match: []string{"/v1/files/a", "/v1/files/a/b/c"}, noMatch: []string{"/v2/files/a"}},
+		{pattern: "/v1/{path=**}", match: []string{"/v1/a/b"}},
+		{pattern: "/v1/a.b/{id}", match: []string{"/v1/a.b/1"}, noMatch: []string{"/v1/axb/1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			re, err := routePathRegexp(tt.pattern)
+			if err != nil {
+				t.Fatalf("转换失败: %v", err)
+			}
+			if tt.plain {
+				if re != nil {
+					t.Fatal("不带参数的路由不应转为正则")
+				}
+				return
+			}
+			for _, p := range tt.match {
+				if !re.MatchString(p) {
+					t.Errorf("%s 应匹配 %s", re, p)
+				}
+			}
+			for _, p := range tt.noMatch {
+				if re.MatchString(p) {
+					t.Errorf("%s 不应匹配 %s", re, p)
+				}
+			}
+		})
+	}
+
+	if _, err := routePathRegexp("/v1/{id"); err == nil {
+		t.Fatal("缺少 } 时应返回错误")
+	}
+}
+
+func newRouteTestConfig(t *testing.T) *ServerConfig {
+	conf := NewServerConfig()
+	conf.Route = []*RouteConfig{
+		{Path: "/hello/say", Envelope: EnvelopeRaw},
+		{Path: "/v1/items/{item_id}", HashKeyByHeader: "X-Uid", Envelope: EnvelopeRaw},
+		{Path: "/v1/files/**"},
+	}
+	if err := conf.Check(); err != nil {
+		t.Fatal(err)
+	}
+	return conf
+}
+
+func TestMatchRouteConfig(t *testing.T) {
+	conf := newRouteTestConfig(t)
+	tests := []struct {
+		path string
+		want string // 匹配的路由, 为空表示不匹配
+	}{
+		{"/hello/say", "/hello/say"},
+		{"/hello/say/1", ""},
+		{"/v1/items/1", "/v1/items/{item_id}"},
+		{"/v1/items/{item_id}", "/v1/items/{item_id}"},
+		{"/v1/items", ""},
+		{"/v1/files/a/b", "/v1/files/**"},
+		{"/other", ""},
+	}
+	for _, tt := range tests {
+		b, ok := conf.MatchRouteConfig(tt.path)
+		got := ""
+		if ok {
+			got = b.Path
+		}
+		if got != tt.want {
+			t.Errorf("%s 匹配的路由为 %q, 期望 %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestCtxRouteConfig(t *testing.T) {
+	conf := newRouteTestConfig(t)
+	ctx := context.Background()
+
+	// proto 中的路由模板和配置的写法不同时, 按请求路径匹配
+	ctx = runtime.NewServerMetadataContext(ctx, runtime.ServerMetadata{})
+	ctx = pkg.SaveGatewayData(ctx, &pkg.GatewayData{Path: "/v1/items/1"})
+	req := httptest.NewRequest(http.MethodGet, "/v1/items/1", nil)
+	ctx, err := runtime.AnnotateIncomingContext(ctx, runtime.NewServeMux(), req, "/a.b/C", runtime.WithHTTPPathPattern("/v1/items/{id}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := conf.ctxRouteConfig(ctx); b == nil || b.Path != "/v1/items/{item_id}" {
+		t.Fatalf("未匹配到带参数的路由: %+v", b)
+	}
+	if conf.envelope(ctx) != EnvelopeRaw {
+		t.Fatal("带参数路由的响应格式未生效")
+	}
+
+	// 没有网关数据时使用默认响应格式
+	if conf.envelope(context.Background()) != conf.Envelope {
+		t.Fatal("应使用默认响应格式")
+	}
+}
+
+func TestReadBody(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		maxSize int64
+		chunked bool // 不设置 Content-Length
+		tooBig  bool
+	}{
+		{name: "未超过", body: "12345", maxSize: 5},
+		{name: "Content-Length超过", body: "123456", maxSize: 5, tooBig: true},
+		{name: "分块超过", body: "123456", maxSize: 5, chunked: true, tooBig: true},
+		{name: "分块未超过", body: "12345", maxSize: 5, chunked: true},
+		{name: "不限制", body: strings.Repeat("a", 100), maxSize: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body io.Reader = strings.NewReader(tt.body)
+			if tt.chunked {
+				body = io.MultiReader(body) // 隐藏长度
+			}
+			r := httptest.NewRequest(http.MethodPost, "/hello/say", body)
+			if tt.chunked {
+				r.ContentLength = -1
+			}
+			got, err := readBody(r, tt.maxSize)
+			if tt.tooBig {
+				var he *runtime.HTTPStatusError
+				if !errors.As(err, &he) || he.HTTPStatus != http.StatusRequestEntityTooLarge {
+					t.Fatalf("应返回413错误, 实际为 %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("读取失败: %v", err)
+			}
+			if string(got) != tt.body {
+				t.Fatalf("读取的body错误: %s", got)
+			}
+		})
+	}
+}
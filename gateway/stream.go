@@ -0,0 +1,198 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// Server-Sent Events
+	MIMEEventStream = "text/event-stream"
+	// 换行分隔的json
+	MIMENdjson = "application/x-ndjson"
+)
+
+// SSE 保活注释
+var sseKeepalive = []byte(": keepalive\n\n")
+
+/*
+流式响应编码器, 根据 Accept 选择.
+
+每条消息都包装为 Response, SSE 的每条消息为一个事件, 错误使用 error 事件; NDJSON 每条消息为一行
+*/
+type streamMarshaler struct {
+	runtime.Marshaler
+	sse bool
+}
+
+func (m *streamMarshaler) ContentType(_ interface{}) string {
+	if m.sse {
+		return MIMEEventStream
+	}
+	return MIMENdjson
+}
+
+func (m *streamMarshaler) Marshal(v interface{}) ([]byte, error) {
+	switch t := v.(type) {
+	case map[string]interface{}: // 流消息, 去掉外层的 result
+		if r, ok := t["result"]; ok && len(t) == 1 {
+			v = r
+		}
+	case map[string]proto.Message: // 流错误
+		if s, ok := t["error"].(*spb.Status); ok && len(t) == 1 {
+			v = statusResponse(s)
+		}
+	}
+	bs, err := m.Marshaler.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if !m.sse {
+		return append(bs, '\n'), nil
+	}
+
+	var buf bytes.Buffer
+	if r, ok := v.(*Response); ok && r.Code != 0 {
+		buf.WriteString("event: error\n")
+	}
+	for _, line := range bytes.Split(bs, []byte("\n")) {
+		buf.WriteString("data: ")
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// 消息已包含分隔符
+func (m *streamMarshaler) Delimiter() []byte {
+	return []byte{}
+}
+
+// 是否为流请求
+func isStreamRequest(r *http.Request) bool {
+	for _, v := range r.Header["Accept"] {
+		if v == MIMEEventStream || v == MIMENdjson {
+			return true
+		}
+	}
+	return strings.HasPrefix(r.Header.Get("Content-Type"), "application/connect+") || websocket.IsWebSocketUpgrade(r)
+}
+
+func isSSERequest(r *http.Request) bool {
+	for _, v := range r.Header["Accept"] {
+		if v == MIMEEventStream {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+流请求的ctx, 不受过滤器超时的限制.
+
+	reqCtx 原始请求的ctx, 客户端断开时取消
+	timeout 流请求的最长时间, 单位秒, 小于1表示不限制
+*/
+func streamContext(ctx, reqCtx context.Context, timeout int) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(reqCtx, cancel)
+	if timeout < 1 {
+		return ctx, func() {
+			stop()
+			cancel()
+		}
+	}
+	ctx, timeoutCancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	return ctx, func() {
+		stop()
+		timeoutCancel()
+		cancel()
+	}
+}
+
+// 流响应写入器, 在空闲时发送 SSE 保活注释
+type streamWriter struct {
+	http.ResponseWriter
+	mu      sync.Mutex
+	started bool // 已经开始写入body
+	closed  bool
+	last    time.Time
+}
+
+func newStreamWriter(w http.ResponseWriter) *streamWriter {
+	return &streamWriter{ResponseWriter: w}
+}
+
+func (w *streamWriter) WriteHeader(statusCode int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *streamWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.started = true
+	w.last = time.Now()
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *streamWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.flush()
+}
+
+func (w *streamWriter) flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *streamWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+/*
+在空闲时发送保活注释, 直到调用返回的函数.
+
+响应开始写入后才会发送, 避免影响响应头和状态码
+*/
+func (w *streamWriter) keepalive(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+			}
+			w.mu.Lock()
+			if !w.closed && w.started && time.Since(w.last) >= interval {
+				if _, err := w.ResponseWriter.Write(sseKeepalive); err == nil {
+					w.flush()
+				}
+				w.last = time.Now()
+			}
+			w.mu.Unlock()
+		}
+	}()
+	return func() {
+		w.mu.Lock()
+		w.closed = true
+		w.mu.Unlock()
+		close(done)
+	}
+}
@@ -0,0 +1,118 @@
+package gateway
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"go.opentelemetry.io/otel"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/zly-app/grpc/pkg"
+)
+
+const tracerName = "github.com/zly-app/grpc/gateway"
+
+type httpSpanKey struct{}
+
+// 开始 http 服务端span
+func startHttpSpan(ctx context.Context, r *http.Request, ip string) (context.Context, trace.Span) {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	ctx, span := otel.Tracer(tracerName).Start(ctx, r.Method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(r.Method),
+			semconv.URLPath(r.URL.Path),
+			semconv.URLScheme(scheme),
+			semconv.ServerAddress(r.Host),
+			semconv.ClientAddress(ip),
+			semconv.UserAgentOriginal(r.UserAgent()),
+		),
+	)
+	return context.WithValue(ctx, httpSpanKey{}, span), span
+}
+
+// 路由匹配后根据路由模板设置span名
+func setHttpSpanRoute(ctx context.Context, r *http.Request) {
+	span, ok := ctx.Value(httpSpanKey{}).(trace.Span)
+	if !ok {
+		return
+	}
+	pattern, ok := runtime.HTTPPathPattern(ctx)
+	if !ok {
+		return
+	}
+	span.SetName(r.Method + " " + pattern)
+	span.SetAttributes(semconv.HTTPRoute(pattern))
+}
+
+// 结束 http 服务端span, 5xx 视为错误
+func endHttpSpan(span trace.Span, statusCode int) {
+	span.SetAttributes(semconv.HTTPResponseStatusCode(statusCode))
+	if statusCode >= http.StatusInternalServerError {
+		span.SetStatus(otelcodes.Error, http.StatusText(statusCode))
+	}
+	span.End()
+}
+
+// 从header中提取trace
+func extractTraceFromHeader(ctx context.Context, p propagation.TextMapPropagator, header http.Header) context.Context {
+	if p == nil {
+		p = pkg.GetPropagator(ctx)
+	}
+	return p.Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// 记录响应状态码的 ResponseWriter
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// 用于 WebSocket 升级
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil && w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *statusWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
@@ -0,0 +1,213 @@
+package gateway
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/websocket"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+
+	"github.com/zly-app/grpc/options"
+)
+
+// 将 gRPC-Web, Connect 和 WebSocket 请求交给对应的处理器, 其它请求交给 h
+func (g *Gateway) webHandler(h http.Handler) http.Handler {
+	web := g.conf.Web
+	if !web.GrpcWeb && !web.Connect && !web.WebSocket {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if web.WebSocket && websocket.IsWebSocketUpgrade(r) {
+			g.serveWebSocket(w, r)
+			return
+		}
+		if r.Method == http.MethodPost {
+			ct := r.Header.Get("Content-Type")
+			switch {
+			case web.GrpcWeb && strings.HasPrefix(ct, "application/grpc-web"):
+				g.serveGrpcWeb(w, r)
+				return
+			case web.Connect && strings.HasPrefix(ct, "application/connect+"):
+				g.serveConnectStream(w, r)
+				return
+			case web.Connect && r.Header.Get("Connect-Protocol-Version") != "":
+				g.serveConnectUnary(w, r)
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// 原始消息, 转发时不解析
+type rawMessage struct {
+	data []byte
+}
+
+// 原始消息编解码器, 直接转发序列化后的数据
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(*rawMessage)
+	if !ok {
+		return nil, fmt.Errorf("rawCodec 不支持的消息类型: %T", v)
+	}
+	return m.data, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(*rawMessage)
+	if !ok {
+		return fmt.Errorf("rawCodec 不支持的消息类型: %T", v)
+	}
+	m.data = append(m.data[:0], data...)
+	return nil
+}
+
+// 使用 proto 作为名称, 服务端按 application/grpc+proto 解析
+func (rawCodec) Name() string { return "proto" }
+
+// 解析方法全名 /package.Service/Method, 返回服务全名
+func parseWebMethod(path string) (string, error) {
+	s := strings.TrimPrefix(path, "/")
+	k := strings.LastIndex(s, "/")
+	if k <= 0 || k == len(s)-1 || strings.Count(s, "/") != 1 {
+		return "", status.Errorf(codes.Unimplemented, "无效的方法路径: %s", path)
+	}
+	return s[:k], nil
+}
+
+// 获取服务转发的grpc服务名
+func (g *Gateway) webServerName(service string) string {
+	for _, s := range g.conf.Web.Services {
+		if s != nil && strings.EqualFold(s.Service, service) {
+			return s.ServerName
+		}
+	}
+	if v, ok := g.dynamicServices.Load(service); ok {
+		return v.(string)
+	}
+	if d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(service)); err == nil {
+		if o, ok := proto.GetExtension(d.Options(), options.E_Service).(*options.ServiceOptions); ok && o.GetServerName() != "" {
+			return o.GetServerName()
+		}
+	}
+	k := strings.LastIndex(service, ".")
+	if k == -1 {
+		return strings.ToLower(service)
+	}
+	pkg := service[:k]
+	return pkg[strings.LastIndex(pkg, ".")+1:]
+}
+
+// 查找方法描述, 依次从动态转码和全局注册表中查找, 不存在时返回 nil
+func (g *Gateway) findMethod(fullMethod string) protoreflect.MethodDescriptor {
+	if v, ok := g.dynamicMethods.Load(fullMethod); ok {
+		return v.(protoreflect.MethodDescriptor)
+	}
+	name := protoreflect.FullName(strings.Replace(strings.TrimPrefix(fullMethod, "/"), "/", ".", 1))
+	d, err := protoregistry.GlobalFiles.FindDescriptorByName(name)
+	if err != nil {
+		return nil
+	}
+	md, _ := d.(protoreflect.MethodDescriptor)
+	return md
+}
+
+/*
+构建调用的ctx, 包含网关数据, 请求id 及用于 hashKey 的路径.
+
+	请求头和http网关一样通过 runtime.AnnotateContext 转发, 只转发 Authorization, Grpc-Metadata-* 及固定的http请求头,
+	避免浏览器通过请求头注入透传数据或请求id等元数据
+*/
+func (g *Gateway) webContext(ctx context.Context, r *http.Request, fullMethod string) (context.Context, error) {
+	return runtime.AnnotateContext(ctx, g.gwMux, r, fullMethod, runtime.WithHTTPPathPattern(r.URL.Path))
+}
+
+/*
+调用grpc方法, 转发原始的请求数据.
+
+	serverStream 为 true 时使用流调用, 可以同时用于一元方法和服务端流方法
+	onHeader 收到响应头时调用
+	send 每收到一个响应消息时调用
+*/
+func webInvoke(ctx context.Context, cc grpc.ClientConnInterface, fullMethod string, serverStream bool, req []byte,
+	onHeader func(md metadata.MD), send func(data []byte) error) (metadata.MD, error) {
+	opt := grpc.ForceCodec(rawCodec{})
+	if !serverStream {
+		var header, trailer metadata.MD
+		rsp := &rawMessage{}
+		err := cc.Invoke(ctx, fullMethod, &rawMessage{data: req}, rsp, opt, grpc.Header(&header), grpc.Trailer(&trailer))
+		onHeader(header)
+		if err != nil {
+			return trailer, err
+		}
+		return trailer, send(rsp.data)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	cs, err := cc.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, fullMethod, opt)
+	if err != nil {
+		onHeader(nil)
+		return nil, err
+	}
+	if err = cs.SendMsg(&rawMessage{data: req}); err != nil && err != io.EOF {
+		onHeader(nil)
+		return nil, err
+	}
+	if err = cs.CloseSend(); err != nil {
+		onHeader(nil)
+		return nil, err
+	}
+	header, _ := cs.Header()
+	onHeader(header)
+	for {
+		rsp := &rawMessage{}
+		err = cs.RecvMsg(rsp)
+		if err == io.EOF {
+			return cs.Trailer(), nil
+		}
+		if err != nil {
+			return cs.Trailer(), err
+		}
+		if err = send(rsp.data); err != nil {
+			return cs.Trailer(), err
+		}
+	}
+}
+
+// 将元数据写入http头, prefix 为key前缀
+func writeMetadataHeader(h http.Header, md metadata.MD, prefix string) {
+	for k, vs := range md {
+		if strings.HasPrefix(k, ":") || k == "content-type" {
+			continue
+		}
+		for _, v := range vs {
+			if strings.HasSuffix(k, "-bin") {
+				v = base64.StdEncoding.EncodeToString([]byte(v))
+			}
+			h.Add(prefix+k, v)
+		}
+	}
+}
+
+// 解码base64, 兼容有无填充
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.TrimRight(s, "=")
+	if strings.ContainsAny(s, "-_") {
+		return base64.RawURLEncoding.DecodeString(s)
+	}
+	return base64.RawStdEncoding.DecodeString(s)
+}
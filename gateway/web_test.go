@@ -0,0 +1,305 @@
+package gateway_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/zly-app/grpc/example/pb/hello"
+	"github.com/zly-app/grpc/gateway"
+	"github.com/zly-app/grpc/grpctest"
+)
+
+type helloService struct {
+	hello.UnimplementedHelloServiceServer
+}
+
+func (helloService) Say(ctx context.Context, req *hello.SayReq) (*hello.SayResp, error) {
+	if req.GetMsg() == "err" {
+		return nil, status.Error(codes.NotFound, "not found")
+	}
+	return &hello.SayResp{Msg: "hello " + req.GetMsg()}, nil
+}
+
+// 启动服务和开启了 gRPC-Web, Connect 及 WebSocket 的网关
+func startWebGateway(t *testing.T, allowOrigins ...string) *httptest.Server {
+	e := grpctest.New(t)
+	e.StartServer("hello", nil, grpctest.Service(&hello.HelloService_ServiceDesc, helloService{}))
+
+	conf := gateway.NewServerConfig()
+	conf.Web.GrpcWeb = true
+	conf.Web.Connect = true
+	conf.Web.WebSocket = true
+	conf.Cors.AllowOrigins = allowOrigins
+	gw := e.StartGateway(conf)
+	srv := httptest.NewServer(gw.Handler())
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// 5字节前缀的消息帧
+func frame(flag byte, data []byte) []byte {
+	b := make([]byte, 5+len(data))
+	b[0] = flag
+	binary.BigEndian.PutUint32(b[1:5], uint32(len(data)))
+	copy(b[5:], data)
+	return b
+}
+
+// 解析响应中的所有帧
+func readFrames(t *testing.T, data []byte) (flags []byte, payloads [][]byte) {
+	t.Helper()
+	for len(data) > 0 {
+		if len(data) < 5 {
+			t.Fatalf("不完整的帧: %v", data)
+		}
+		n := binary.BigEndian.Uint32(data[1:5])
+		if uint32(len(data)-5) < n {
+			t.Fatalf("不完整的帧: %v", data)
+		}
+		flags = append(flags, data[0])
+		payloads = append(payloads, data[5:5+n])
+		data = data[5+n:]
+	}
+	return flags, payloads
+}
+
+// 解码 grpc-web-text 响应, 每帧单独进行base64编码
+func decodeTextFrames(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var ret []byte
+	s := string(data)
+	for len(s) > 0 {
+		end := len(s)
+		if k := strings.IndexByte(s, '='); k != -1 {
+			end = k + len(s[k:]) - len(strings.TrimLeft(s[k:], "="))
+		}
+		bs, err := base64.StdEncoding.DecodeString(s[:end])
+		if err != nil {
+			t.Fatalf("解码响应失败: %v", err)
+		}
+		ret = append(ret, bs...)
+		s = s[end:]
+	}
+	return ret
+}
+
+func post(t *testing.T, url, contentType string, body []byte, header http.Header) (*http.Response, []byte) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+	req.Header.Set("Content-Type", contentType)
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	defer rsp.Body.Close()
+	bs, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		t.Fatalf("读取响应失败: %v", err)
+	}
+	return rsp, bs
+}
+
+func TestGrpcWeb(t *testing.T) {
+	srv := startWebGateway(t)
+	url := srv.URL + hello.HelloService_Say_FullMethodName
+
+	tests := []struct {
+		name   string
+		text   bool
+		msg    string
+		status string
+		reply  string
+	}{
+		{name: "binary", msg: "a", status: "0", reply: "hello a"},
+		{name: "text", text: true, msg: "b", status: "0", reply: "hello b"},
+		{name: "error", msg: "err", status: "5"},
+		{name: "text error", text: true, msg: "err", status: "5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reqData, _ := proto.Marshal(&hello.SayReq{Msg: tt.msg})
+			body := frame(0, reqData)
+			ct := "application/grpc-web+proto"
+			if tt.text {
+				// 分两段编码, 每段都有填充
+				body = []byte(base64.StdEncoding.EncodeToString(body[:4]) + base64.StdEncoding.EncodeToString(body[4:]))
+				ct = "application/grpc-web-text+proto"
+			}
+			rsp, data := post(t, url, ct, body, nil)
+			if rsp.StatusCode != http.StatusOK {
+				t.Fatalf("状态码错误: %d", rsp.StatusCode)
+			}
+			if rsp.Header.Get("Content-Type") != ct {
+				t.Fatalf("Content-Type 错误: %s", rsp.Header.Get("Content-Type"))
+			}
+			if tt.text {
+				data = decodeTextFrames(t, data)
+			}
+
+			flags, payloads := readFrames(t, data)
+			if len(flags) == 0 || flags[len(flags)-1] != 0x80 {
+				t.Fatalf("最后一帧应为尾部帧: %v", flags)
+			}
+			trailer := string(payloads[len(payloads)-1])
+			if !strings.Contains(trailer, "grpc-status: "+tt.status+"\r\n") {
+				t.Fatalf("尾部帧状态错误: %q", trailer)
+			}
+			if tt.status != "0" {
+				if len(flags) != 1 || !strings.Contains(trailer, "grpc-message: not found\r\n") {
+					t.Fatalf("错误响应不应有数据帧且应有错误消息: %v, %q", flags, trailer)
+				}
+				return
+			}
+			if len(flags) != 2 || flags[0] != 0 {
+				t.Fatalf("帧错误: %v", flags)
+			}
+			out := &hello.SayResp{}
+			if err := proto.Unmarshal(payloads[0], out); err != nil {
+				t.Fatalf("解析响应失败: %v", err)
+			}
+			if out.GetMsg() != tt.reply {
+				t.Fatalf("响应错误: %s", out.GetMsg())
+			}
+		})
+	}
+}
+
+func TestConnectUnary(t *testing.T) {
+	srv := startWebGateway(t)
+	url := srv.URL + hello.HelloService_Say_FullMethodName
+	header := http.Header{"Connect-Protocol-Version": {"1"}}
+
+	t.Run("json", func(t *testing.T) {
+		rsp, data := post(t, url, "application/json", []byte(`{"msg":"a"}`), header)
+		if rsp.StatusCode != http.StatusOK || rsp.Header.Get("Content-Type") != "application/json" {
+			t.Fatalf("响应错误: %d, %s", rsp.StatusCode, rsp.Header.Get("Content-Type"))
+		}
+		var out map[string]string
+		if err := json.Unmarshal(data, &out); err != nil || out["msg"] != "hello a" {
+			t.Fatalf("响应错误: %s, %v", data, err)
+		}
+	})
+
+	t.Run("proto", func(t *testing.T) {
+		reqData, _ := proto.Marshal(&hello.SayReq{Msg: "b"})
+		rsp, data := post(t, url, "application/proto", reqData, header)
+		if rsp.StatusCode != http.StatusOK || rsp.Header.Get("Content-Type") != "application/proto" {
+			t.Fatalf("响应错误: %d, %s", rsp.StatusCode, rsp.Header.Get("Content-Type"))
+		}
+		out := &hello.SayResp{}
+		if err := proto.Unmarshal(data, out); err != nil || out.GetMsg() != "hello b" {
+			t.Fatalf("响应错误: %s, %v", out.GetMsg(), err)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		rsp, data := post(t, url, "application/json", []byte(`{"msg":"err"}`), header)
+		if rsp.StatusCode != http.StatusNotFound || rsp.Header.Get("Content-Type") != "application/json" {
+			t.Fatalf("错误响应错误: %d, %s", rsp.StatusCode, rsp.Header.Get("Content-Type"))
+		}
+		var ce struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		}
+		if err := json.Unmarshal(data, &ce); err != nil || ce.Code != "not_found" || ce.Message != "not found" {
+			t.Fatalf("错误响应错误: %s, %v", data, err)
+		}
+	})
+}
+
+func TestConnectStream(t *testing.T) {
+	srv := startWebGateway(t)
+	url := srv.URL + hello.HelloService_Say_FullMethodName
+	header := http.Header{"Connect-Protocol-Version": {"1"}}
+
+	t.Run("ok", func(t *testing.T) {
+		rsp, data := post(t, url, "application/connect+json", frame(0, []byte(`{"msg":"a"}`)), header)
+		if rsp.StatusCode != http.StatusOK || rsp.Header.Get("Content-Type") != "application/connect+json" {
+			t.Fatalf("响应错误: %d, %s", rsp.StatusCode, rsp.Header.Get("Content-Type"))
+		}
+		flags, payloads := readFrames(t, data)
+		if len(flags) != 2 || flags[0] != 0 || flags[1] != 0x02 {
+			t.Fatalf("帧错误: %v", flags)
+		}
+		var out map[string]string
+		if err := json.Unmarshal(payloads[0], &out); err != nil || out["msg"] != "hello a" {
+			t.Fatalf("响应错误: %s, %v", payloads[0], err)
+		}
+		var end map[string]any
+		if err := json.Unmarshal(payloads[1], &end); err != nil || end["error"] != nil {
+			t.Fatalf("结束帧错误: %s, %v", payloads[1], err)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		rsp, data := post(t, url, "application/connect+json", frame(0, []byte(`{"msg":"err"}`)), header)
+		if rsp.StatusCode != http.StatusOK {
+			t.Fatalf("流错误的状态码应为200: %d", rsp.StatusCode)
+		}
+		flags, payloads := readFrames(t, data)
+		if len(flags) != 1 || flags[0] != 0x02 {
+			t.Fatalf("帧错误: %v", flags)
+		}
+		var end struct {
+			Error struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(payloads[0], &end); err != nil || end.Error.Code != "not_found" || end.Error.Message != "not found" {
+			t.Fatalf("结束帧错误: %s, %v", payloads[0], err)
+		}
+	})
+}
+
+func TestWebSocketOrigin(t *testing.T) {
+	srv := startWebGateway(t, "https://a.com")
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + hello.HelloService_Say_FullMethodName
+
+	tests := []struct {
+		origin string
+		ok     bool
+	}{
+		{origin: "", ok: true},
+		{origin: srv.URL, ok: true}, // 同源
+		{origin: "https://a.com", ok: true},
+		{origin: "https://evil.example", ok: false},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		if tt.origin != "" {
+			header.Set("Origin", tt.origin)
+		}
+		conn, rsp, err := websocket.DefaultDialer.Dial(url, header)
+		if tt.ok {
+			if err != nil {
+				t.Fatalf("来源 %q 应允许连接: %v", tt.origin, err)
+			}
+			_ = conn.Close()
+			continue
+		}
+		if err == nil {
+			_ = conn.Close()
+			t.Fatalf("来源 %q 不应允许连接", tt.origin)
+		}
+		if rsp == nil || rsp.StatusCode != http.StatusForbidden {
+			t.Fatalf("来源 %q 应返回403: %v", tt.origin, rsp)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package gateway
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const (
+	// WebSocket 错误关闭码的起始值, 关闭码为 wsCloseCodeBase + grpc错误码
+	wsCloseCodeBase = 4000
+	// 单个消息的最大长度, 和grpc默认的最大接收消息长度一致
+	wsReadLimit = 4 << 20
+	// 关闭原因的最大字节数
+	wsMaxCloseReason = 123
+	// 写控制帧的超时
+	wsWriteWait = 5 * time.Second
+)
+
+// WebSocket 消息的json编码, 和网关响应的字段名一致
+var wsMarshalOptions = protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}
+
+// 处理 WebSocket 请求, 路径为 /package.Service/Method, 每个帧对应一个请求或响应消息
+func (g *Gateway) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	fullMethod := r.URL.Path
+	service, err := parseWebMethod(fullMethod)
+	if err != nil {
+		g.httpError(w, r, err)
+		return
+	}
+	md := g.findMethod(fullMethod)
+	// 升级请求的请求头按 http 网关的规则作为元数据转发, 包括认证信息
+	ctx, err := g.webContext(r.Context(), r, fullMethod)
+	if err != nil {
+		g.httpError(w, r, err)
+		return
+	}
+
+	upgrader := websocket.Upgrader{CheckOrigin: g.wsCheckOrigin}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // Upgrade 已经返回了错误响应
+	}
+	defer conn.Close()
+	conn.SetReadLimit(wsReadLimit)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	b := &wsBridge{conn: conn, md: md, cancel: cancel}
+	desc := &grpc.StreamDesc{ServerStreams: true, ClientStreams: true}
+	if md != nil {
+		desc = &grpc.StreamDesc{ServerStreams: md.IsStreamingServer(), ClientStreams: md.IsStreamingClient()}
+	}
+	cs, err := GetGatewayClientConn(g.webServerName(service)).NewStream(ctx, desc, fullMethod, grpc.ForceCodec(rawCodec{}))
+	if err != nil {
+		b.close(err)
+		return
+	}
+	if g.conf.Stream.Keepalive > 0 {
+		defer b.ping(time.Duration(g.conf.Stream.Keepalive) * time.Second)()
+	}
+	go b.readLoop(cs)
+	b.close(b.writeLoop(cs))
+}
+
+/*
+检查 WebSocket 请求来源, 允许同源及 Cors.AllowOrigins 中明确设置的来源.
+
+	WebSocket 不受浏览器跨域限制, 升级请求会携带cookie, 所以不使用 * 或 CorsAllowAll, 避免任意网站以用户身份建立连接
+*/
+func (g *Gateway) wsCheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if g.cors != nil && g.cors.allowExplicitOrigin(origin) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// 返回http错误, 用于升级前的错误
+func (g *Gateway) httpError(w http.ResponseWriter, r *http.Request, err error) {
+	_, outbound := runtime.MarshalerForRequest(g.gwMux, r)
+	runtime.HTTPError(r.Context(), g.gwMux, outbound, w, r, err)
+}
+
+// WebSocket 和grpc流的桥接
+type wsBridge struct {
+	conn   *websocket.Conn
+	md     protoreflect.MethodDescriptor // 方法描述, 为 nil 时只支持二进制帧
+	cancel context.CancelFunc
+
+	msgType atomic.Int32 // 响应的帧类型, 和最近一次请求的帧类型一致
+	mu      sync.Mutex
+	err     error // 桥接的错误, 优先于grpc流的错误
+}
+
+// 读取请求帧并发送到grpc流, 客户端关闭时结束发送
+func (b *wsBridge) readLoop(cs grpc.ClientStream) {
+	b.conn.SetCloseHandler(func(code int, text string) error {
+		return nil // 不立即回复关闭帧, 等待grpc流结束后再关闭
+	})
+	for {
+		mt, data, err := b.conn.ReadMessage()
+		if err != nil {
+			if _, ok := err.(*websocket.CloseError); ok {
+				_ = cs.CloseSend()
+				return
+			}
+			b.fail(status.Error(codes.Canceled, "WebSocket 连接已断开"))
+			return
+		}
+		b.msgType.Store(int32(mt))
+		req, err := b.decode(mt, data)
+		if err != nil {
+			b.fail(err)
+			return
+		}
+		if err = cs.SendMsg(&rawMessage{data: req}); err != nil {
+			return // 流已结束, 错误由 RecvMsg 返回
+		}
+	}
+}
+
+// 将响应写入帧, 返回grpc流的错误
+func (b *wsBridge) writeLoop(cs grpc.ClientStream) error {
+	for {
+		rsp := &rawMessage{}
+		err := cs.RecvMsg(rsp)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		mt, data, err := b.encode(rsp.data)
+		if err != nil {
+			b.fail(err)
+			return err
+		}
+		if err = b.conn.WriteMessage(mt, data); err != nil {
+			b.fail(status.Error(codes.Canceled, "WebSocket 连接已断开"))
+			return err
+		}
+	}
+}
+
+// 记录错误并取消grpc流
+func (b *wsBridge) fail(err error) {
+	b.mu.Lock()
+	if b.err == nil {
+		b.err = err
+	}
+	b.mu.Unlock()
+	b.cancel()
+}
+
+// 解码请求帧, 文本帧为json, 二进制帧为proto
+func (b *wsBridge) decode(mt int, data []byte) ([]byte, error) {
+	if mt == websocket.BinaryMessage {
+		return data, nil
+	}
+	if b.md == nil {
+		return nil, status.Error(codes.Unimplemented, "未找到方法的描述, 只支持二进制帧")
+	}
+	msg := dynamicpb.NewMessage(b.md.Input())
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, msg); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "解析请求失败: %v", err)
+	}
+	return proto.Marshal(msg)
+}
+
+// 编码响应, 帧类型和最近一次请求一致, 还没有请求时有方法描述则使用文本帧
+func (b *wsBridge) encode(data []byte) (int, []byte, error) {
+	mt := int(b.msgType.Load())
+	if mt == 0 {
+		mt = websocket.TextMessage
+		if b.md == nil {
+			mt = websocket.BinaryMessage
+		}
+	}
+	if mt == websocket.BinaryMessage {
+		return mt, data, nil
+	}
+	msg := dynamicpb.NewMessage(b.md.Output())
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return 0, nil, status.Errorf(codes.Internal, "解析响应失败: %v", err)
+	}
+	bs, err := wsMarshalOptions.Marshal(msg)
+	return mt, bs, err
+}
+
+// 发送关闭帧, 正常结束为 1000, 错误为 4000 + grpc错误码
+func (b *wsBridge) close(err error) {
+	b.mu.Lock()
+	if b.err != nil {
+		err = b.err
+	}
+	b.mu.Unlock()
+	code, reason := websocket.CloseNormalClosure, ""
+	if err != nil {
+		s := status.Convert(err)
+		code, reason = wsCloseCodeBase+int(s.Code()), truncateCloseReason(s.Message())
+	}
+	_ = b.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(wsWriteWait))
+}
+
+// 定时发送 ping, 直到调用返回的函数
+func (b *wsBridge) ping(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				_ = b.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait))
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// 关闭原因最多 123 字节
+func truncateCloseReason(s string) string {
+	if len(s) <= wsMaxCloseReason {
+		return s
+	}
+	s = s[:wsMaxCloseReason]
+	for len(s) > 0 && !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
@@ -1,6 +1,7 @@
 package gateway
 
 import (
+	"context"
 	"sync"
 
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
@@ -75,6 +76,14 @@ func GetGatewayMux() *runtime.ServeMux {
 	return defService.server.GetMux()
 }
 
+// 加载动态转码的proto描述并注册路由, 需要在网关启动前调用
+func RegisterDynamic(ctx context.Context, conf *DynamicConfig) error {
+	if defService == nil {
+		log.Fatal("grpc 网关服务未启用")
+	}
+	return defService.server.RegisterDynamic(ctx, conf)
+}
+
 // 获取grpc客户端conn
 func GetGatewayClientConn(serverName string) client.ClientConnInterface {
 	return newConn(serverName)
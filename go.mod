@@ -20,7 +20,14 @@ require (
 	google.golang.org/protobuf v1.36.10
 )
 
-require google.golang.org/genproto/googleapis/api v0.0.0-20250929231259-57b25ae835d4
+require (
+	github.com/gorilla/websocket v1.5.3
+	go.opentelemetry.io/contrib/propagators/b3 v1.37.0
+	go.opentelemetry.io/contrib/propagators/jaeger v1.37.0
+	go.opentelemetry.io/otel/metric v1.37.0
+	go.opentelemetry.io/otel/trace v1.37.0
+	google.golang.org/genproto/googleapis/api v0.0.0-20250929231259-57b25ae835d4
+)
 
 require (
 	github.com/bytedance/gopkg v0.1.3 // indirect
@@ -55,8 +62,6 @@ require (
 	github.com/yusufpapurcu/wmi v1.2.3 // indirect
 	github.com/zlyuancn/zstr v0.0.0-20230412074414-14d6b645962f // indirect
 	go.opentelemetry.io/auto/sdk v1.1.0 // indirect
-	go.opentelemetry.io/otel/metric v1.37.0 // indirect
-	go.opentelemetry.io/otel/trace v1.37.0 // indirect
 	go.uber.org/atomic v1.7.0 // indirect
 	go.uber.org/automaxprocs v1.5.1 // indirect
 	go.uber.org/goleak v1.1.12 // indirect
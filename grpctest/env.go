@@ -0,0 +1,84 @@
+/*
+测试工具, 在内存中启动 grpc 服务和网关, 不需要监听端口.
+
+	func TestSay(t *testing.T) {
+		env := grpctest.New(t)
+		env.StartServer("hello", nil, grpctest.Service(&hello.HelloService_ServiceDesc, new(HelloService)))
+
+		c := hello.NewHelloServiceClient(client.GetClientConn("hello"))
+		rsp, err := c.Say(context.Background(), &hello.SayReq{Msg: "hi"})
+		...
+	}
+*/
+package grpctest
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/zly-app/zapp"
+	"github.com/zly-app/zapp/config"
+	"github.com/zly-app/zapp/core"
+	"github.com/zly-app/zapp/pkg/zlog"
+
+	"github.com/zly-app/grpc/client"
+)
+
+var (
+	testApp     core.IApp
+	testAppOnce sync.Once
+	addrSeq     atomic.Int64
+)
+
+// 获取测试使用的app. 如果已经创建了app则使用它, 否则创建一个debug模式的app. app在进程内只会创建一次
+func App() core.IApp {
+	testAppOnce.Do(func() {
+		if app := zapp.App(); app != nil {
+			testApp = app
+			return
+		}
+		conf := &core.Config{}
+		conf.Frame.Debug = true // debug模式下不会屏蔽服务端的错误
+		conf.Frame.Log = zlog.DefaultConfig
+		testApp = zapp.NewApp("grpc-test", zapp.WithConfigOption(config.WithConfig(conf)))
+	})
+	return testApp
+}
+
+// 测试环境, 测试结束时会自动关闭其中启动的服务, 网关及客户端
+type Env struct {
+	t   testing.TB
+	app core.IApp
+
+	clientConf map[string]*client.ClientConfig
+}
+
+// 创建测试环境. 同名的服务不能在并行的测试中同时启动
+func New(t testing.TB) *Env {
+	t.Helper()
+	return &Env{
+		t:          t,
+		app:        App(),
+		clientConf: make(map[string]*client.ClientConfig),
+	}
+}
+
+func (e *Env) App() core.IApp {
+	return e.app
+}
+
+/*
+设置客户端配置, 需要在 StartServer 之前调用. Address 会被替换为内存中的服务地址.
+
+未设置时使用默认配置
+*/
+func (e *Env) SetClientConfig(serverName string, conf *client.ClientConfig) {
+	e.clientConf[serverName] = conf
+}
+
+// 生成一个内存中的服务地址
+func newAddress(serverName string) string {
+	return fmt.Sprintf("bufconn.%s.%d:1", serverName, addrSeq.Add(1))
+}
@@ -0,0 +1,216 @@
+package grpctest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// 方法处理函数
+type FakeHandler = func(ctx context.Context, req proto.Message) (proto.Message, error)
+
+// 记录的调用
+type Call struct {
+	Method   string          // 方法, 如 /hello.HelloService/Say
+	Request  proto.Message   // 请求的副本
+	Metadata metadata.MD     // 请求携带的元数据
+	Time     time.Time       // 调用时间
+	Err      error           // 返回的错误
+	Ctx      context.Context // 调用时的ctx
+}
+
+/*
+可编程的 fake 客户端conn, 实现了 client.ClientConnInterface, 不需要启动服务.
+
+	f := grpctest.NewFakeConn()
+	f.OnMethod("/hello.HelloService/Say").Return(&hello.SayResp{Msg: "hi"})
+	grpctest.UseFakeConn(t, "hello", f)
+*/
+type FakeConn struct {
+	mx      sync.Mutex
+	methods map[string]*MethodStub
+	calls   []*Call
+}
+
+func NewFakeConn() *FakeConn {
+	return &FakeConn{methods: make(map[string]*MethodStub)}
+}
+
+// 方法桩, 设置方法的响应, 错误和延迟
+type MethodStub struct {
+	mx      sync.Mutex
+	rsp     proto.Message
+	err     error
+	delay   time.Duration
+	handler FakeHandler
+}
+
+// 获取方法桩, 不存在时创建. method 如 /hello.HelloService/Say
+func (f *FakeConn) OnMethod(method string) *MethodStub {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	s, ok := f.methods[method]
+	if !ok {
+		s = &MethodStub{}
+		f.methods[method] = s
+	}
+	return s
+}
+
+// 返回响应
+func (s *MethodStub) Return(rsp proto.Message) *MethodStub {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.rsp, s.err, s.handler = rsp, nil, nil
+	return s
+}
+
+// 返回错误, 如 status.Error(codes.NotFound, "not found")
+func (s *MethodStub) ReturnError(err error) *MethodStub {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.rsp, s.err, s.handler = nil, err, nil
+	return s
+}
+
+// 使用函数处理请求, 可以根据请求返回不同的响应
+func (s *MethodStub) Handle(fn FakeHandler) *MethodStub {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.rsp, s.err, s.handler = nil, nil, fn
+	return s
+}
+
+// 响应前的延迟, ctx 超时或取消时返回对应的错误
+func (s *MethodStub) Delay(d time.Duration) *MethodStub {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.delay = d
+	return s
+}
+
+// 使用类型化的函数处理请求
+func HandleMethod[Req, Rsp proto.Message](f *FakeConn, method string, fn func(ctx context.Context, req Req) (Rsp, error)) *MethodStub {
+	return f.OnMethod(method).Handle(func(ctx context.Context, req proto.Message) (proto.Message, error) {
+		r, ok := req.(Req)
+		if !ok {
+			return nil, status.Errorf(codes.Internal, "请求类型不匹配: %T", req)
+		}
+		return fn(ctx, r)
+	})
+}
+
+func (s *MethodStub) call(ctx context.Context, req proto.Message) (proto.Message, error) {
+	s.mx.Lock()
+	rsp, err, delay, handler := s.rsp, s.err, s.delay, s.handler
+	s.mx.Unlock()
+
+	if delay > 0 {
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, status.FromContextError(ctx.Err()).Err()
+		case <-t.C:
+		}
+	}
+	if handler != nil {
+		return handler(ctx, req)
+	}
+	return rsp, err
+}
+
+func (f *FakeConn) Invoke(ctx context.Context, method string, args interface{}, reply interface{}, opts ...grpc.CallOption) error {
+	req, ok := args.(proto.Message)
+	if !ok {
+		return status.Errorf(codes.Internal, "请求不是proto消息: %T", args)
+	}
+	md, _ := metadata.FromOutgoingContext(ctx)
+	call := &Call{
+		Method:   method,
+		Request:  proto.Clone(req),
+		Metadata: md.Copy(),
+		Time:     time.Now(),
+		Ctx:      ctx,
+	}
+
+	f.mx.Lock()
+	f.calls = append(f.calls, call)
+	s, ok := f.methods[method]
+	f.mx.Unlock()
+
+	err := status.Errorf(codes.Unimplemented, "fake conn 未设置方法 %s", method)
+	if ok {
+		err = f.invoke(ctx, s, req, reply)
+	}
+	f.mx.Lock()
+	call.Err = err
+	f.mx.Unlock()
+	return err
+}
+
+func (f *FakeConn) invoke(ctx context.Context, s *MethodStub, req proto.Message, reply interface{}) error {
+	rsp, err := s.call(ctx, req)
+	if err != nil {
+		return err
+	}
+	out, ok := reply.(proto.Message)
+	if !ok {
+		return status.Errorf(codes.Internal, "响应不是proto消息: %T", reply)
+	}
+	proto.Reset(out)
+	if rsp == nil {
+		return nil
+	}
+	if rsp.ProtoReflect().Descriptor() != out.ProtoReflect().Descriptor() {
+		return status.Errorf(codes.Internal, "响应类型不匹配, 期望 %T, 实际 %T", out, rsp)
+	}
+	proto.Merge(out, rsp)
+	return nil
+}
+
+func (f *FakeConn) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return nil, status.Error(codes.Unimplemented, "fake conn 不支持stream")
+}
+
+// 获取记录的调用的副本, method 为空表示所有方法. 未完成的调用 Err 为 nil
+func (f *FakeConn) Calls(method string) []*Call {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	ret := make([]*Call, 0, len(f.calls))
+	for _, c := range f.calls {
+		if method == "" || c.Method == method {
+			cp := *c
+			ret = append(ret, &cp)
+		}
+	}
+	return ret
+}
+
+// 获取方法的调用次数, method 为空表示所有方法
+func (f *FakeConn) CallCount(method string) int {
+	return len(f.Calls(method))
+}
+
+// 获取方法最后一次调用, 没有调用时返回 nil
+func (f *FakeConn) LastCall(method string) *Call {
+	calls := f.Calls(method)
+	if len(calls) == 0 {
+		return nil
+	}
+	return calls[len(calls)-1]
+}
+
+// 清空记录的调用和设置的方法
+func (f *FakeConn) Reset() {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	f.methods = make(map[string]*MethodStub)
+	f.calls = nil
+}
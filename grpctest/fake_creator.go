@@ -0,0 +1,88 @@
+package grpctest
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/zly-app/grpc/client"
+)
+
+// fake 客户端建造者, 设置了 fake conn 的服务返回 fake conn, 其它服务使用默认的建造者
+type fakeCreator struct {
+	mx      sync.RWMutex
+	conns   map[string]client.ClientConnInterface
+	restore func()
+}
+
+var defFakeCreator = &fakeCreator{conns: make(map[string]client.ClientConnInterface)}
+
+func (c *fakeCreator) get(serverName string) (client.ClientConnInterface, bool) {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+	cc, ok := c.conns[serverName]
+	return cc, ok
+}
+
+func (c *fakeCreator) GetClientConn(serverName string) client.ClientConnInterface {
+	if cc, ok := c.get(serverName); ok {
+		return cc
+	}
+	return client.DefaultClientCreator().GetClientConn(serverName)
+}
+
+func (c *fakeCreator) GetPoolStats(serverName string) (client.PoolStats, bool) {
+	if _, ok := c.get(serverName); ok {
+		return client.PoolStats{}, false
+	}
+	return client.DefaultClientCreator().GetPoolStats(serverName)
+}
+
+func (c *fakeCreator) GetAllPoolStats() []client.PoolStats {
+	return client.DefaultClientCreator().GetAllPoolStats()
+}
+
+func (c *fakeCreator) GetAllDebugState() []*client.DebugState {
+	return client.DefaultClientCreator().GetAllDebugState()
+}
+
+func (c *fakeCreator) CloseClient(serverName string) {
+	if _, ok := c.get(serverName); ok {
+		return
+	}
+	client.DefaultClientCreator().CloseClient(serverName)
+}
+
+// 不关闭默认的建造者, 其它测试可能还在使用. 默认建造者会在app退出时关闭
+func (c *fakeCreator) Close() {}
+
+/*
+在测试范围内将 client.GetClientConn(serverName) 替换为 cc, 测试结束时恢复.
+
+	cc 一般为 NewFakeConn 创建的 fake conn
+	未设置的服务仍然使用默认的客户端建造者
+*/
+func UseFakeConn(t testing.TB, serverName string, cc client.ClientConnInterface) {
+	t.Helper()
+	c := defFakeCreator
+	c.mx.Lock()
+	old, existed := c.conns[serverName]
+	c.conns[serverName] = cc
+	if c.restore == nil {
+		c.restore = client.SetClientCreator(c)
+	}
+	c.mx.Unlock()
+
+	t.Cleanup(func() {
+		c.mx.Lock()
+		defer c.mx.Unlock()
+		if existed {
+			c.conns[serverName] = old
+			return
+		}
+		delete(c.conns, serverName)
+		if len(c.conns) == 0 && c.restore != nil {
+			c.restore()
+			c.restore = nil
+		}
+	})
+}
@@ -0,0 +1,123 @@
+package grpctest
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/bytedance/sonic"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/zly-app/grpc/gateway"
+)
+
+// 网关处理器注册函数, 如
+//
+//	func(ctx context.Context, mux *runtime.ServeMux) error {
+//		return hello.RegisterHelloServiceHandlerClient(ctx, mux, hello.NewHelloServiceClient(gateway.GetGatewayClientConn("hello")))
+//	}
+type GatewayRegister = func(ctx context.Context, mux *runtime.ServeMux) error
+
+// 测试网关, 请求直接交给网关的http处理器, 不需要监听端口
+type Gateway struct {
+	env *Env
+	gw  *gateway.Gateway
+}
+
+/*
+创建网关.
+
+	conf 网关配置, 为 nil 时使用默认配置. 通过反射获取描述的动态转码会在创建时加载, 需要先启动服务
+	register 注册网关处理器, 网关通过 gateway.GetGatewayClientConn 连接 StartServer 启动的服务
+*/
+func (e *Env) StartGateway(conf *gateway.ServerConfig, register ...GatewayRegister) *Gateway {
+	e.t.Helper()
+	if conf == nil {
+		conf = gateway.NewServerConfig()
+	}
+	gw, err := gateway.NewGateway(e.app, conf)
+	if err != nil {
+		e.t.Fatalf("创建grpc网关失败: %v", err)
+	}
+	for _, fn := range register {
+		if err = fn(context.Background(), gw.GetMux()); err != nil {
+			e.t.Fatalf("注册网关处理器失败: %v", err)
+		}
+	}
+	if err = gw.LoadReflectionDynamic(context.Background()); err != nil {
+		e.t.Fatalf("加载网关动态转码失败: %v", err)
+	}
+	return &Gateway{env: e, gw: gw}
+}
+
+func (g *Gateway) Mux() *runtime.ServeMux {
+	return g.gw.GetMux()
+}
+
+// 网关http处理器, 可以用于 httptest.NewServer
+func (g *Gateway) Handler() http.Handler {
+	return g.gw.Handler()
+}
+
+// 发送请求
+func (g *Gateway) Do(r *http.Request) *HttpResponse {
+	w := httptest.NewRecorder()
+	g.gw.Handler().ServeHTTP(w, r)
+	rsp := w.Result()
+	body, _ := io.ReadAll(rsp.Body)
+	_ = rsp.Body.Close()
+	return &HttpResponse{StatusCode: rsp.StatusCode, Header: rsp.Header, Body: body}
+}
+
+/*
+发送请求.
+
+	body 请求body, 如 json 字符串, 为空表示没有body
+	header 请求header, 可以为 nil
+*/
+func (g *Gateway) Request(method, path, body string, header http.Header) *HttpResponse {
+	var r *http.Request
+	if body == "" {
+		r = httptest.NewRequest(method, path, nil)
+	} else {
+		r = httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+	}
+	for k, vs := range header {
+		r.Header[k] = vs
+	}
+	return g.Do(r)
+}
+
+// 网关http响应
+type HttpResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// 解析网关包装后的响应, Data 为 map 等通用类型
+func (r *HttpResponse) Response() (*gateway.Response, error) {
+	ret := &gateway.Response{}
+	err := sonic.Unmarshal(r.Body, ret)
+	return ret, err
+}
+
+// 将响应的 data 解析到 proto 消息
+func (r *HttpResponse) Data(out proto.Message) error {
+	var rsp struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := sonic.Unmarshal(r.Body, &rsp); err != nil {
+		return err
+	}
+	if len(rsp.Data) == 0 {
+		return nil
+	}
+	return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(rsp.Data, out)
+}
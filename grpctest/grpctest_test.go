@@ -0,0 +1,182 @@
+package grpctest_test
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+
+	"github.com/zly-app/grpc/client"
+	"github.com/zly-app/grpc/example/pb/hello"
+	"github.com/zly-app/grpc/gateway"
+	"github.com/zly-app/grpc/grpctest"
+)
+
+type helloService struct {
+	hello.UnimplementedHelloServiceServer
+}
+
+func (helloService) Say(ctx context.Context, req *hello.SayReq) (*hello.SayResp, error) {
+	return &hello.SayResp{Msg: "hello " + req.GetMsg()}, nil
+}
+
+func registerHelloGateway(ctx context.Context, mux *runtime.ServeMux) error {
+	return hello.RegisterHelloServiceHandlerClient(ctx, mux, hello.NewHelloServiceClient(gateway.GetGatewayClientConn("hello")))
+}
+
+func TestServerAndGateway(t *testing.T) {
+	e := grpctest.New(t)
+	e.StartServer("hello", nil, grpctest.Service(&hello.HelloService_ServiceDesc, helloService{}))
+
+	rsp, err := hello.NewHelloServiceClient(client.GetClientConn("hello")).Say(context.Background(), &hello.SayReq{Msg: "a"})
+	if err != nil {
+		t.Fatalf("调用失败: %v", err)
+	}
+	if rsp.GetMsg() != "hello a" {
+		t.Fatalf("响应错误: %s", rsp.GetMsg())
+	}
+
+	gw := e.StartGateway(nil, registerHelloGateway)
+	httpRsp := gw.Request(http.MethodPost, "/hello/say", `{"msg":"b"}`, nil)
+	if httpRsp.StatusCode != http.StatusOK {
+		t.Fatalf("网关状态码错误: %d, %s", httpRsp.StatusCode, httpRsp.Body)
+	}
+	out := &hello.SayResp{}
+	if err = httpRsp.Data(out); err != nil {
+		t.Fatalf("解析网关响应失败: %v", err)
+	}
+	if out.GetMsg() != "hello b" {
+		t.Fatalf("网关响应错误: %s", out.GetMsg())
+	}
+}
+
+func TestFakeConn(t *testing.T) {
+	e := grpctest.New(t)
+	e.StartServer("hello", nil, grpctest.Service(&hello.HelloService_ServiceDesc, helloService{}))
+	real := hello.NewHelloServiceClient(client.GetClientConn("hello"))
+	if _, err := real.Say(context.Background(), &hello.SayReq{Msg: "a"}); err != nil {
+		t.Fatalf("调用失败: %v", err)
+	}
+
+	t.Run("fake", func(t *testing.T) {
+		f := grpctest.NewFakeConn()
+		f.OnMethod(hello.HelloService_Say_FullMethodName).Return(&hello.SayResp{Msg: "fake"})
+		grpctest.UseFakeConn(t, "hello", f)
+
+		gw := e.StartGateway(nil, registerHelloGateway)
+		out := &hello.SayResp{}
+		if err := gw.Request(http.MethodPost, "/hello/say", `{"msg":"b"}`, nil).Data(out); err != nil {
+			t.Fatalf("解析网关响应失败: %v", err)
+		}
+		if out.GetMsg() != "fake" {
+			t.Fatalf("网关响应错误: %s", out.GetMsg())
+		}
+		if n := f.CallCount(hello.HelloService_Say_FullMethodName); n != 1 {
+			t.Fatalf("调用次数错误: %d", n)
+		}
+	})
+
+	// fake 恢复后不能影响已创建的客户端
+	rsp, err := real.Say(context.Background(), &hello.SayReq{Msg: "c"})
+	if err != nil {
+		t.Fatalf("恢复后调用失败: %v", err)
+	}
+	if rsp.GetMsg() != "hello c" {
+		t.Fatalf("恢复后响应错误: %s", rsp.GetMsg())
+	}
+}
+
+func TestClientStreamReleaseConn(t *testing.T) {
+	e := grpctest.New(t)
+	e.StartServer("hello", nil, grpctest.Service(&hello.HelloService_ServiceDesc, helloService{}))
+	cc := client.GetClientConn("hello")
+	active := func() int64 {
+		s, _ := client.GetPoolStats("hello")
+		return s.Active
+	}
+
+	// 读取到响应后归还连接
+	cs, err := cc.NewStream(context.Background(), &grpc.StreamDesc{}, hello.HelloService_Say_FullMethodName)
+	if err != nil {
+		t.Fatalf("创建流失败: %v", err)
+	}
+	if err = cs.SendMsg(&hello.SayReq{Msg: "a"}); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	_ = cs.CloseSend()
+	out := &hello.SayResp{}
+	if err = cs.RecvMsg(out); err != nil {
+		t.Fatalf("接收失败: %v", err)
+	}
+	if out.GetMsg() != "hello a" {
+		t.Fatalf("响应错误: %s", out.GetMsg())
+	}
+	if n := active(); n != 0 {
+		t.Fatalf("流结束后未归还连接: %d", n)
+	}
+
+	// 未读取时取消ctx归还连接
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err = cc.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, hello.HelloService_Say_FullMethodName)
+	if err != nil {
+		t.Fatalf("创建流失败: %v", err)
+	}
+	if n := active(); n != 1 {
+		t.Fatalf("流未结束时应占用连接: %d", n)
+	}
+	cancel()
+	deadline := time.Now().Add(time.Second)
+	for active() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("取消ctx后未归还连接: %d", active())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// 调用进行中读取记录的调用不应有数据竞争
+func TestFakeConnCallsRace(t *testing.T) {
+	f := grpctest.NewFakeConn()
+	f.OnMethod(hello.HelloService_Say_FullMethodName).Return(&hello.SayResp{Msg: "fake"}).Delay(time.Millisecond)
+	c := hello.NewHelloServiceClient(f)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = c.Say(context.Background(), &hello.SayReq{Msg: "a"})
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		for _, call := range f.Calls("") {
+			_ = call.Err
+		}
+		if call := f.LastCall(hello.HelloService_Say_FullMethodName); call != nil {
+			_ = call.Err
+		}
+	}
+
+	if n := f.CallCount(hello.HelloService_Say_FullMethodName); n != 10 {
+		t.Fatalf("调用次数错误: %d", n)
+	}
+	for _, call := range f.Calls("") {
+		if call.Err != nil {
+			t.Fatalf("调用失败: %v", call.Err)
+		}
+	}
+}
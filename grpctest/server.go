@@ -0,0 +1,73 @@
+package grpctest
+
+import (
+	"context"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/zly-app/grpc/client"
+	"github.com/zly-app/grpc/fault"
+	"github.com/zly-app/grpc/registry/static"
+	"github.com/zly-app/grpc/server"
+)
+
+// 内存连接的缓冲区大小
+const bufSize = 1 << 20
+
+// 服务描述及其实现
+type ServiceDesc struct {
+	Desc *grpc.ServiceDesc
+	Impl interface{}
+}
+
+// 服务描述及其实现, 如 grpctest.Service(&hello.HelloService_ServiceDesc, impl)
+func Service(desc *grpc.ServiceDesc, impl interface{}) ServiceDesc {
+	return ServiceDesc{Desc: desc, Impl: impl}
+}
+
+/*
+在内存中启动服务, 请求会经过和正常启动时相同的拦截器链.
+
+	conf 服务配置, 为 nil 时使用默认配置
+	之后 client.GetClientConn(serverName) 会通过静态注册器连接到这个服务
+*/
+func (e *Env) StartServer(serverName string, conf *server.ServerConfig, services ...ServiceDesc) *server.GRpcServer {
+	e.t.Helper()
+	if conf == nil {
+		conf = server.NewServerConfig()
+	}
+	g, err := server.NewGRpcServer(e.app, conf)
+	if err != nil {
+		e.t.Fatalf("创建grpc服务失败: %v", err)
+	}
+	for _, s := range services {
+		g.RegisterService(serverName, s.Desc, s.Impl)
+	}
+
+	lis := bufconn.Listen(bufSize)
+	addr := newAddress(serverName)
+	client.RegisterDialer(addr, func(ctx context.Context, _ string) (net.Conn, error) {
+		return lis.DialContext(ctx)
+	})
+	go func() { _ = g.Serve(lis) }()
+
+	// 客户端配置
+	cc := client.NewClientConfig()
+	if c, ok := e.clientConf[serverName]; ok {
+		v := *c
+		cc = &v
+	}
+	cc.Address = addr
+	e.app.GetConfig().GetViper().Set("components."+string(client.DefaultComponentType)+"."+serverName, cc)
+
+	e.t.Cleanup(func() {
+		client.CloseClient(serverName)
+		static.DefStatic.UnRegistry(context.Background(), serverName)
+		client.UnregisterDialer(addr)
+		fault.Unregister("server/" + serverName)
+		g.Close()
+	})
+	return g
+}
@@ -2,12 +2,14 @@ package grpc
 
 import (
 	"context"
-	"encoding/base64"
 
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/zly-app/grpc/pkg"
 )
 
-const WrapMetadataPrefix = "cw_"
+const WrapMetadataPrefix = pkg.CustomDataPrefix
 
 // 服务端提取请求方传入的数据
 func ServerExtractCustomData(ctx context.Context, key string) []string {
@@ -31,6 +33,37 @@ func ClientInjectCustomData(ctx context.Context, key string, value []string) con
 }
 
 func makeCustomDataKey(key string) string {
-	k := WrapMetadataPrefix + key
-	return base64.StdEncoding.EncodeToString([]byte(k))
+	return pkg.MakeCustomDataKey(key)
+}
+
+// 获取透传数据, 服务端配置 PassThroughKeys 中的key会从上游请求中提取
+var GetPassThroughData = pkg.GetPassThroughData
+
+// 设置透传数据, 使用返回的ctx请求下游时会自动带上
+var WithPassThroughData = pkg.WithPassThroughData
+
+// 获取请求id, 网关或服务端收到请求时如果上游没有传入会生成一个新的
+var GetRequestId = pkg.GetRequestId
+
+// 上下文值的编解码器
+type Codec[T any] = pkg.Codec[T]
+
+// 字符串编解码器
+type StringCodec = pkg.StringCodec
+
+// 整数编解码器
+type IntCodec = pkg.IntCodec
+
+// json编解码器, 使用二进制元数据传输
+type JSONCodec[T any] = pkg.JSONCodec[T]
+
+// proto编解码器, 使用二进制元数据传输
+type ProtoCodec[T proto.Message] = pkg.ProtoCodec[T]
+
+// 类型化的上下文key
+type ContextKey[T any] = pkg.ContextKey[T]
+
+// 定义类型化的上下文key, 客户端通过 Inject 注入值, 服务端通过 Extract 提取值
+func DefineContextKey[T any](name string, codec Codec[T]) *ContextKey[T] {
+	return pkg.DefineContextKey[T](name, codec)
 }
@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/zly-app/grpc/pkg"
+)
+
+// 服务端rpc指标拦截器
+func UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	mdIn, _ := metadata.FromIncomingContext(ctx)
+	callerMeta, _ := pkg.ExtractCallerMetaFromMD(mdIn)
+
+	if callerMeta.CallerService != "" { // 调用方服务只记录到span
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("caller.service", callerMeta.CallerService))
+	}
+
+	r := serverMetrics.start(ctx, info.FullMethod, "", req)
+	reply, err := handler(ctx, req)
+	r.end(ctx, err, reply)
+	return reply, err
+}
+
+// 客户端rpc指标拦截器
+func UnaryClientInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	mdOut, _ := metadata.FromOutgoingContext(ctx)
+	callerMeta, _ := pkg.ExtractCallerMetaFromMD(mdOut)
+
+	r := clientMetrics.start(ctx, method, callerMeta.CallerService, req)
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	r.end(ctx, err, reply)
+	return err
+}
@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/zly-app/zapp/filter"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/zly-app/grpc/pkg"
+)
+
+func TestUnaryServerInterceptorCallerLabel(t *testing.T) {
+	md := metadata.MD{}
+	pkg.InjectCallerMetaToMD(context.Background(), md, filter.CallerMeta{CallerService: "evil-caller"})
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Svc/Caller"}
+	_, err := UnaryServerInterceptor(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	DefaultRegistry().WriteText(&buf)
+	text := buf.String()
+	if !strings.Contains(text, `grpc_server_handled_total{rpc_service="test.Svc",rpc_method="Caller",rpc_grpc_status_code="OK"} 1`) {
+		t.Fatalf("缺少服务端指标:\n%s", text)
+	}
+	if strings.Contains(text, "evil-caller") {
+		t.Fatalf("服务端指标不应包含调用方服务标签:\n%s", text)
+	}
+}
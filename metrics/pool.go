@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// 连接池标签
+const LabelClient = "client"
+
+// 连接池当前状态
+type PoolState struct {
+	Active  int64 // 正在使用的连接数
+	Idle    int64 // 空闲连接数
+	Open    int64 // 已建立的连接数
+	Waiting int64 // 正在等待获取连接的请求数
+}
+
+var (
+	pools   = map[string]func() PoolState{}
+	poolsMx sync.RWMutex
+)
+
+var (
+	poolCreateFailures = defRegistry.NewCounter("grpc_client_pool_create_failures_total", "连接池创建连接失败数", LabelClient)
+	poolGetFailures    = defRegistry.NewCounter("grpc_client_pool_get_failures_total", "连接池获取连接失败数", LabelClient)
+	poolWaitMsec       = defRegistry.NewHistogram("grpc_client_pool_wait_msec", "连接池获取连接等待耗时, 单位毫秒", durationBounds, LabelClient)
+
+	otelPoolCreateFailures metric.Int64Counter
+	otelPoolGetFailures    metric.Int64Counter
+	otelPoolWait           metric.Float64Histogram
+)
+
+func init() {
+	gauges := []struct {
+		name, help string
+		value      func(s PoolState) int64
+	}{
+		{"active", "连接池正在使用的连接数", func(s PoolState) int64 { return s.Active }},
+		{"idle", "连接池空闲连接数", func(s PoolState) int64 { return s.Idle }},
+		{"open", "连接池已建立的连接数", func(s PoolState) int64 { return s.Open }},
+		{"waiting", "连接池正在等待获取连接的请求数", func(s PoolState) int64 { return s.Waiting }},
+	}
+
+	meter := otel.Meter(meterName)
+	for _, g := range gauges {
+		value := g.value
+		defRegistry.NewGaugeFunc("grpc_client_pool_"+g.name, g.help, func(emit func(value float64, labelValues ...string)) {
+			rangePools(func(name string, s PoolState) { emit(float64(value(s)), name) })
+		}, LabelClient)
+
+		_, _ = meter.Int64ObservableGauge("rpc.client.pool."+g.name, metric.WithDescription(g.help),
+			metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+				rangePools(func(name string, s PoolState) {
+					o.Observe(value(s), metric.WithAttributes(attribute.String(LabelClient, name)))
+				})
+				return nil
+			}))
+	}
+
+	otelPoolCreateFailures, _ = meter.Int64Counter("rpc.client.pool.create_failures", metric.WithDescription("连接池创建连接失败数"))
+	otelPoolGetFailures, _ = meter.Int64Counter("rpc.client.pool.get_failures", metric.WithDescription("连接池获取连接失败数"))
+	otelPoolWait, _ = meter.Float64Histogram("rpc.client.pool.wait", metric.WithUnit("ms"),
+		metric.WithDescription("连接池获取连接等待耗时"), metric.WithExplicitBucketBoundaries(durationBounds...))
+}
+
+// 注册连接池, 导出指标时会调用 state 获取连接池当前状态
+func RegisterPool(clientName string, state func() PoolState) {
+	poolsMx.Lock()
+	defer poolsMx.Unlock()
+	pools[clientName] = state
+}
+
+// 取消注册连接池
+func UnRegisterPool(clientName string) {
+	poolsMx.Lock()
+	defer poolsMx.Unlock()
+	delete(pools, clientName)
+}
+
+func rangePools(fn func(name string, s PoolState)) {
+	poolsMx.RLock()
+	names := make([]string, 0, len(pools))
+	for name := range pools {
+		names = append(names, name)
+	}
+	poolsMx.RUnlock()
+	sort.Strings(names)
+
+	for _, name := range names {
+		poolsMx.RLock()
+		state, ok := pools[name]
+		poolsMx.RUnlock()
+		if ok {
+			fn(name, state())
+		}
+	}
+}
+
+// 记录获取连接的等待耗时
+func ObservePoolWait(ctx context.Context, clientName string, d time.Duration) {
+	ms := float64(d) / float64(time.Millisecond)
+	poolWaitMsec.Observe(ms, clientName)
+	otelPoolWait.Record(ctx, ms, metric.WithAttributes(attribute.String(LabelClient, clientName)))
+}
+
+// 记录创建连接失败
+func IncPoolCreateFailure(ctx context.Context, clientName string) {
+	poolCreateFailures.Add(1, clientName)
+	otelPoolCreateFailures.Add(ctx, 1, metric.WithAttributes(attribute.String(LabelClient, clientName)))
+}
+
+// 记录获取连接失败
+func IncPoolGetFailure(ctx context.Context, clientName string) {
+	poolGetFailures.Add(1, clientName)
+	otelPoolGetFailures.Add(ctx, 1, metric.WithAttributes(attribute.String(LabelClient, clientName)))
+}
@@ -0,0 +1,290 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 指标集合, 可以按 prometheus 文本格式导出
+type Registry struct {
+	mx         sync.RWMutex
+	collectors []collector
+}
+
+type collector interface {
+	write(buf *bytes.Buffer)
+}
+
+var defRegistry = &Registry{}
+
+func (r *Registry) register(c collector) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// 按 prometheus 文本格式写出所有指标
+func (r *Registry) WriteText(buf *bytes.Buffer) {
+	r.mx.RLock()
+	defer r.mx.RUnlock()
+	for _, c := range r.collectors {
+		c.write(buf)
+	}
+}
+
+// prometheus 文本格式的指标http处理器
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	buf := &bytes.Buffer{}
+	r.WriteText(buf)
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = w.Write(buf.Bytes())
+}
+
+// 获取 prometheus 文本格式的指标http处理器
+func Handler() http.Handler {
+	return defRegistry
+}
+
+type metricVec struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+
+	mx     sync.RWMutex
+	values map[string]*metricValue
+}
+
+type metricValue struct {
+	labelValues []string
+
+	mx      sync.Mutex
+	value   float64
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+func newMetricVec(name, help, typ string, labels []string) *metricVec {
+	return &metricVec{name: name, help: help, typ: typ, labels: labels, values: make(map[string]*metricValue)}
+}
+
+func (m *metricVec) get(labelValues []string, bucketNum int) *metricValue {
+	key := strings.Join(labelValues, "\xff")
+	m.mx.RLock()
+	v, ok := m.values[key]
+	m.mx.RUnlock()
+	if ok {
+		return v
+	}
+
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if v, ok = m.values[key]; ok {
+		return v
+	}
+	v = &metricValue{labelValues: append([]string(nil), labelValues...)}
+	if bucketNum > 0 {
+		v.buckets = make([]uint64, bucketNum)
+	}
+	m.values[key] = v
+	return v
+}
+
+func (m *metricVec) sortedValues() []*metricValue {
+	m.mx.RLock()
+	keys := make([]string, 0, len(m.values))
+	for k := range m.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	ret := make([]*metricValue, len(keys))
+	for i, k := range keys {
+		ret[i] = m.values[k]
+	}
+	m.mx.RUnlock()
+	return ret
+}
+
+func (m *metricVec) writeHeader(buf *bytes.Buffer) {
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.typ)
+}
+
+func (m *metricVec) writeLabels(buf *bytes.Buffer, labelValues []string, extraName, extraValue string) {
+	if len(m.labels) == 0 && extraName == "" {
+		return
+	}
+	buf.WriteByte('{')
+	for i, l := range m.labels {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		writeLabel(buf, l, labelValues[i])
+	}
+	if extraName != "" {
+		if len(m.labels) > 0 {
+			buf.WriteByte(',')
+		}
+		writeLabel(buf, extraName, extraValue)
+	}
+	buf.WriteByte('}')
+}
+
+// 写入标签, 值按prometheus文本格式转义, 只转义 \\, \" 和 \n
+func writeLabel(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(name)
+	buf.WriteString(`="`)
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '\\':
+			buf.WriteString(`\\`)
+		case '"':
+			buf.WriteString(`\"`)
+		case '\n':
+			buf.WriteString(`\n`)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// 计数器或仪表
+type ValueVec struct {
+	*metricVec
+}
+
+func (v ValueVec) Add(delta float64, labelValues ...string) {
+	mv := v.get(labelValues, 0)
+	mv.mx.Lock()
+	mv.value += delta
+	mv.mx.Unlock()
+}
+
+func (v ValueVec) Set(value float64, labelValues ...string) {
+	mv := v.get(labelValues, 0)
+	mv.mx.Lock()
+	mv.value = value
+	mv.mx.Unlock()
+}
+
+func (v ValueVec) write(buf *bytes.Buffer) {
+	v.writeHeader(buf)
+	for _, mv := range v.sortedValues() {
+		mv.mx.Lock()
+		value := mv.value
+		mv.mx.Unlock()
+
+		buf.WriteString(v.name)
+		v.writeLabels(buf, mv.labelValues, "", "")
+		fmt.Fprintf(buf, " %s\n", formatFloat(value))
+	}
+}
+
+// 直方图
+type HistogramVec struct {
+	*metricVec
+	bounds []float64
+}
+
+func (h HistogramVec) Observe(value float64, labelValues ...string) {
+	mv := h.get(labelValues, len(h.bounds))
+	mv.mx.Lock()
+	for i, b := range h.bounds {
+		if value <= b {
+			mv.buckets[i]++
+		}
+	}
+	mv.sum += value
+	mv.count++
+	mv.mx.Unlock()
+}
+
+func (h HistogramVec) write(buf *bytes.Buffer) {
+	h.writeHeader(buf)
+	for _, mv := range h.sortedValues() {
+		mv.mx.Lock()
+		buckets := append([]uint64(nil), mv.buckets...)
+		sum, count := mv.sum, mv.count
+		mv.mx.Unlock()
+
+		for i, b := range h.bounds {
+			buf.WriteString(h.name + "_bucket")
+			h.writeLabels(buf, mv.labelValues, "le", formatFloat(b))
+			fmt.Fprintf(buf, " %d\n", buckets[i])
+		}
+		buf.WriteString(h.name + "_bucket")
+		h.writeLabels(buf, mv.labelValues, "le", "+Inf")
+		fmt.Fprintf(buf, " %d\n", count)
+
+		buf.WriteString(h.name + "_sum")
+		h.writeLabels(buf, mv.labelValues, "", "")
+		fmt.Fprintf(buf, " %s\n", formatFloat(sum))
+
+		buf.WriteString(h.name + "_count")
+		h.writeLabels(buf, mv.labelValues, "", "")
+		fmt.Fprintf(buf, " %d\n", count)
+	}
+}
+
+// 注册计数器
+func (r *Registry) NewCounter(name, help string, labels ...string) ValueVec {
+	v := ValueVec{newMetricVec(name, help, "counter", labels)}
+	r.register(v)
+	return v
+}
+
+// 注册仪表
+func (r *Registry) NewGauge(name, help string, labels ...string) ValueVec {
+	v := ValueVec{newMetricVec(name, help, "gauge", labels)}
+	r.register(v)
+	return v
+}
+
+// 注册直方图
+func (r *Registry) NewHistogram(name, help string, bounds []float64, labels ...string) HistogramVec {
+	h := HistogramVec{metricVec: newMetricVec(name, help, "histogram", labels), bounds: bounds}
+	r.register(h)
+	return h
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// 获取默认的指标集合
+func DefaultRegistry() *Registry {
+	return defRegistry
+}
+
+// 在导出时计算值的仪表
+type gaugeFunc struct {
+	*metricVec
+	fn func(emit func(value float64, labelValues ...string))
+}
+
+func (g gaugeFunc) write(buf *bytes.Buffer) {
+	g.writeHeader(buf)
+	g.fn(func(value float64, labelValues ...string) {
+		buf.WriteString(g.name)
+		g.writeLabels(buf, labelValues, "", "")
+		fmt.Fprintf(buf, " %s\n", formatFloat(value))
+	})
+}
+
+// 注册在导出时计算值的仪表, fn 中通过 emit 输出每一组标签的值
+func (r *Registry) NewGaugeFunc(name, help string, fn func(emit func(value float64, labelValues ...string)), labels ...string) {
+	r.register(gaugeFunc{metricVec: newMetricVec(name, help, "gauge", labels), fn: fn})
+}
@@ -0,0 +1,155 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/zly-app/grpc/pkg"
+)
+
+const meterName = "github.com/zly-app/grpc"
+
+const (
+	KindServer = "server"
+	KindClient = "client"
+)
+
+// 指标标签
+const (
+	LabelService       = "rpc_service"
+	LabelMethod        = "rpc_method"
+	LabelCode          = "rpc_grpc_status_code"
+	LabelCallerService = "caller_service"
+)
+
+var (
+	durationBounds = []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+	sizeBounds     = []float64{64, 256, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304}
+)
+
+// 一类rpc(服务端或客户端)的指标
+type rpcMetrics struct {
+	// prometheus
+	requests     ValueVec
+	duration     HistogramVec
+	inFlight     ValueVec
+	requestSize  HistogramVec
+	responseSize HistogramVec
+
+	// otel
+	otelRequests     metric.Int64Counter
+	otelDuration     metric.Float64Histogram
+	otelInFlight     metric.Int64UpDownCounter
+	otelRequestSize  metric.Int64Histogram
+	otelResponseSize metric.Int64Histogram
+
+	withCaller bool // 是否包含调用方服务标签
+}
+
+var (
+	// 服务端的调用方服务来自请求元数据, 不可控, 不作为标签
+	serverMetrics = newRpcMetrics(KindServer, false)
+	clientMetrics = newRpcMetrics(KindClient, true)
+)
+
+func newRpcMetrics(kind string, withCaller bool) *rpcMetrics {
+	labels := []string{LabelService, LabelMethod}
+	if withCaller {
+		labels = append(labels, LabelCallerService)
+	}
+	codeLabels := append(append([]string(nil), labels...), LabelCode)
+	prefix := "grpc_" + kind + "_"
+
+	m := &rpcMetrics{
+		requests:     defRegistry.NewCounter(prefix+"handled_total", "rpc请求数", codeLabels...),
+		duration:     defRegistry.NewHistogram(prefix+"handling_msec", "rpc耗时, 单位毫秒", durationBounds, codeLabels...),
+		inFlight:     defRegistry.NewGauge(prefix+"in_flight", "正在处理的rpc请求数", labels...),
+		requestSize:  defRegistry.NewHistogram(prefix+"request_bytes", "rpc请求消息大小, 单位字节", sizeBounds, labels...),
+		responseSize: defRegistry.NewHistogram(prefix+"response_bytes", "rpc响应消息大小, 单位字节", sizeBounds, labels...),
+		withCaller:   withCaller,
+	}
+
+	meter := otel.Meter(meterName)
+	otelPrefix := "rpc." + kind + "."
+	m.otelRequests, _ = meter.Int64Counter(otelPrefix+"requests", metric.WithDescription("rpc请求数"))
+	m.otelDuration, _ = meter.Float64Histogram(otelPrefix+"duration", metric.WithUnit("ms"),
+		metric.WithDescription("rpc耗时"), metric.WithExplicitBucketBoundaries(durationBounds...))
+	m.otelInFlight, _ = meter.Int64UpDownCounter(otelPrefix+"active_requests", metric.WithDescription("正在处理的rpc请求数"))
+	m.otelRequestSize, _ = meter.Int64Histogram(otelPrefix+"request.size", metric.WithUnit("By"), metric.WithDescription("rpc请求消息大小"))
+	m.otelResponseSize, _ = meter.Int64Histogram(otelPrefix+"response.size", metric.WithUnit("By"), metric.WithDescription("rpc响应消息大小"))
+	return m
+}
+
+// 一次rpc调用的指标记录器
+type rpcRecorder struct {
+	m         *rpcMetrics
+	labels    []string // 标签值
+	startTime time.Time
+	attrs     []attribute.KeyValue
+}
+
+func (m *rpcMetrics) start(ctx context.Context, fullMethod, callerService string, req interface{}) *rpcRecorder {
+	service, method := SplitMethod(fullMethod)
+	r := &rpcRecorder{
+		m:         m,
+		labels:    []string{service, method},
+		startTime: time.Now(),
+		attrs: []attribute.KeyValue{
+			attribute.String("rpc.system", "grpc"),
+			attribute.String("rpc.service", service),
+			attribute.String("rpc.method", method),
+		},
+	}
+	if m.withCaller {
+		r.labels = append(r.labels, callerService)
+		r.attrs = append(r.attrs, attribute.String("caller.service", callerService))
+	}
+	m.inFlight.Add(1, r.labels...)
+	m.otelInFlight.Add(ctx, 1, metric.WithAttributes(r.attrs...))
+
+	size := MessageSize(req)
+	m.requestSize.Observe(float64(size), r.labels...)
+	m.otelRequestSize.Record(ctx, int64(size), metric.WithAttributes(r.attrs...))
+	return r
+}
+
+func (r *rpcRecorder) end(ctx context.Context, err error, reply interface{}) {
+	m := r.m
+	m.inFlight.Add(-1, r.labels...)
+	m.otelInFlight.Add(ctx, -1, metric.WithAttributes(r.attrs...))
+
+	code := status.Code(err).String()
+	if err == nil {
+		size := MessageSize(reply)
+		m.responseSize.Observe(float64(size), r.labels...)
+		m.otelResponseSize.Record(ctx, int64(size), metric.WithAttributes(r.attrs...))
+	}
+
+	ms := float64(time.Since(r.startTime)) / float64(time.Millisecond)
+	codeLabels := append(append([]string(nil), r.labels...), code)
+	m.requests.Add(1, codeLabels...)
+	m.duration.Observe(ms, codeLabels...)
+
+	codeAttrs := append(r.attrs, attribute.String("rpc.grpc.status_code", code))
+	m.otelRequests.Add(ctx, 1, metric.WithAttributes(codeAttrs...))
+	m.otelDuration.Record(ctx, ms, metric.WithAttributes(codeAttrs...))
+}
+
+// 将 /package.Service/Method 拆分为服务名和方法名
+func SplitMethod(fullMethod string) (string, string) {
+	return pkg.SplitMethod(fullMethod)
+}
+
+// 获取消息序列化后的大小
+func MessageSize(msg interface{}) int {
+	if m, ok := msg.(proto.Message); ok {
+		return proto.Size(m)
+	}
+	return 0
+}
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/zly-app/zapp/core"
+	"github.com/zly-app/zapp/handler"
+	"go.uber.org/zap"
+)
+
+// 指标http路径
+const HttpPath = "/metrics"
+
+var startedBind = map[string]struct{}{}
+var startedBindMx sync.Mutex
+
+// 启动 prometheus 指标http服务, 同一个bind地址只会启动一次
+func StartServer(app core.IApp, bind string) error {
+	startedBindMx.Lock()
+	defer startedBindMx.Unlock()
+	if _, ok := startedBind[bind]; ok {
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", bind)
+	if err != nil {
+		return err
+	}
+	startedBind[bind] = struct{}{}
+
+	mux := http.NewServeMux()
+	mux.Handle(HttpPath, Handler())
+	server := &http.Server{Handler: mux}
+	handler.AddHandler(handler.BeforeExitHandler, func(app core.IApp, handlerType handler.HandlerType) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = server.Shutdown(ctx)
+	})
+
+	app.Info("正在启动grpc指标服务", zap.String("bind", listener.Addr().String()))
+	go func() {
+		err := server.Serve(listener)
+		if err != nil && err != http.ErrServerClosed {
+			app.Error("grpc指标服务异常退出", zap.String("bind", bind), zap.Error(err))
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,54 @@
+package options
+
+import (
+	"strings"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// 方法全名 -> *MethodOptions
+var methodCache sync.Map
+
+/*
+获取方法选项, fullMethod 如 /hello.HelloService/Say.
+
+从全局注册表 protoregistry.GlobalFiles 中查找方法描述, 方法未注册或未设置选项时返回空的选项.
+方法设置了 idempotency_level = IDEMPOTENT 或 NO_SIDE_EFFECTS 时 Idempotent 为 true. 返回的选项不能修改
+*/
+func Lookup(fullMethod string) *MethodOptions {
+	if v, ok := methodCache.Load(fullMethod); ok {
+		return v.(*MethodOptions)
+	}
+	o := lookup(fullMethod)
+	methodCache.Store(fullMethod, o)
+	return o
+}
+
+func lookup(fullMethod string) *MethodOptions {
+	ret := &MethodOptions{}
+	name := protoreflect.FullName(strings.Replace(strings.TrimPrefix(fullMethod, "/"), "/", ".", 1))
+	d, err := protoregistry.GlobalFiles.FindDescriptorByName(name)
+	if err != nil {
+		return ret
+	}
+	md, ok := d.(protoreflect.MethodDescriptor)
+	if !ok {
+		return ret
+	}
+	opts, _ := md.Options().(*descriptorpb.MethodOptions)
+	if opts == nil {
+		return ret
+	}
+	if o, ok := proto.GetExtension(opts, E_Method).(*MethodOptions); ok && o != nil {
+		ret = proto.Clone(o).(*MethodOptions)
+	}
+	switch opts.GetIdempotencyLevel() {
+	case descriptorpb.MethodOptions_IDEMPOTENT, descriptorpb.MethodOptions_NO_SIDE_EFFECTS:
+		ret.Idempotent = true
+	}
+	return ret
+}
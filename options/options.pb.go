@@ -0,0 +1,430 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        v3.21.10
+// source: zapp/grpc/options.proto
+
+package options
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	descriptorpb "google.golang.org/protobuf/types/descriptorpb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// 服务选项
+type ServiceOptions struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// 在 zapp 中的服务名, 用于 grpc.Server 和 grpc.GetClientConn. 默认为 proto 包名的最后一段
+	ServerName    string `protobuf:"bytes,1,opt,name=server_name,json=serverName,proto3" json:"server_name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServiceOptions) Reset() {
+	*x = ServiceOptions{}
+	mi := &file_zapp_grpc_options_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServiceOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServiceOptions) ProtoMessage() {}
+
+func (x *ServiceOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_zapp_grpc_options_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServiceOptions.ProtoReflect.Descriptor instead.
+func (*ServiceOptions) Descriptor() ([]byte, []int) {
+	return file_zapp_grpc_options_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ServiceOptions) GetServerName() string {
+	if x != nil {
+		return x.ServerName
+	}
+	return ""
+}
+
+// 方法选项, 服务端和客户端在运行时从方法描述中读取
+type MethodOptions struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// 调用超时, 单位毫秒. 调用方未设置更短的超时时间时生效
+	TimeoutMs uint32 `protobuf:"varint,1,opt,name=timeout_ms,json=timeoutMs,proto3" json:"timeout_ms,omitempty"`
+	// 是否幂等, 也可以使用 option idempotency_level = IDEMPOTENT. 非幂等方法只会在 UNAVAILABLE 时重试
+	Idempotent bool `protobuf:"varint,2,opt,name=idempotent,proto3" json:"idempotent,omitempty"`
+	// 客户端重试策略
+	Retry *RetryPolicy `protobuf:"bytes,3,opt,name=retry,proto3" json:"retry,omitempty"`
+	// 是否需要认证, 服务端会调用 grpc.SetAuthFunc 设置的认证函数, 未设置时返回 Unauthenticated
+	AuthRequired bool `protobuf:"varint,4,opt,name=auth_required,json=authRequired,proto3" json:"auth_required,omitempty"`
+	// 服务端限流, 超过时返回 RESOURCE_EXHAUSTED
+	RateLimit *RateLimit `protobuf:"bytes,5,opt,name=rate_limit,json=rateLimit,proto3" json:"rate_limit,omitempty"`
+	// 客户端是否缓存响应, 以请求内容, 调用方的 authorization 及透传数据为key, 响应不能依赖其它元数据
+	Cacheable bool `protobuf:"varint,6,opt,name=cacheable,proto3" json:"cacheable,omitempty"`
+	// 缓存时间, 单位毫秒, 默认1000
+	CacheTtlMs uint32 `protobuf:"varint,7,opt,name=cache_ttl_ms,json=cacheTtlMs,proto3" json:"cache_ttl_ms,omitempty"`
+	// 访问日志中需要脱敏的字段名模式, 会和访问日志配置的 RedactFields 合并, 如 phone, *_no
+	RedactFields  []string `protobuf:"bytes,8,rep,name=redact_fields,json=redactFields,proto3" json:"redact_fields,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MethodOptions) Reset() {
+	*x = MethodOptions{}
+	mi := &file_zapp_grpc_options_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MethodOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MethodOptions) ProtoMessage() {}
+
+func (x *MethodOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_zapp_grpc_options_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MethodOptions.ProtoReflect.Descriptor instead.
+func (*MethodOptions) Descriptor() ([]byte, []int) {
+	return file_zapp_grpc_options_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *MethodOptions) GetTimeoutMs() uint32 {
+	if x != nil {
+		return x.TimeoutMs
+	}
+	return 0
+}
+
+func (x *MethodOptions) GetIdempotent() bool {
+	if x != nil {
+		return x.Idempotent
+	}
+	return false
+}
+
+func (x *MethodOptions) GetRetry() *RetryPolicy {
+	if x != nil {
+		return x.Retry
+	}
+	return nil
+}
+
+func (x *MethodOptions) GetAuthRequired() bool {
+	if x != nil {
+		return x.AuthRequired
+	}
+	return false
+}
+
+func (x *MethodOptions) GetRateLimit() *RateLimit {
+	if x != nil {
+		return x.RateLimit
+	}
+	return nil
+}
+
+func (x *MethodOptions) GetCacheable() bool {
+	if x != nil {
+		return x.Cacheable
+	}
+	return false
+}
+
+func (x *MethodOptions) GetCacheTtlMs() uint32 {
+	if x != nil {
+		return x.CacheTtlMs
+	}
+	return 0
+}
+
+func (x *MethodOptions) GetRedactFields() []string {
+	if x != nil {
+		return x.RedactFields
+	}
+	return nil
+}
+
+// 重试策略
+type RetryPolicy struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// 最大尝试次数, 包含第一次调用, 小于2表示不重试
+	MaxAttempts uint32 `protobuf:"varint,1,opt,name=max_attempts,json=maxAttempts,proto3" json:"max_attempts,omitempty"`
+	// 第一次重试前的等待时间, 单位毫秒, 之后每次翻倍, 默认100
+	InitialBackoffMs uint32 `protobuf:"varint,2,opt,name=initial_backoff_ms,json=initialBackoffMs,proto3" json:"initial_backoff_ms,omitempty"`
+	// 最大等待时间, 单位毫秒, 默认1000
+	MaxBackoffMs uint32 `protobuf:"varint,3,opt,name=max_backoff_ms,json=maxBackoffMs,proto3" json:"max_backoff_ms,omitempty"`
+	// 可重试的错误码, 支持名称或数字, 如 UNAVAILABLE, RESOURCE_EXHAUSTED, 14. 默认 UNAVAILABLE
+	RetryableCodes []string `protobuf:"bytes,4,rep,name=retryable_codes,json=retryableCodes,proto3" json:"retryable_codes,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *RetryPolicy) Reset() {
+	*x = RetryPolicy{}
+	mi := &file_zapp_grpc_options_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RetryPolicy) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RetryPolicy) ProtoMessage() {}
+
+func (x *RetryPolicy) ProtoReflect() protoreflect.Message {
+	mi := &file_zapp_grpc_options_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RetryPolicy.ProtoReflect.Descriptor instead.
+func (*RetryPolicy) Descriptor() ([]byte, []int) {
+	return file_zapp_grpc_options_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *RetryPolicy) GetMaxAttempts() uint32 {
+	if x != nil {
+		return x.MaxAttempts
+	}
+	return 0
+}
+
+func (x *RetryPolicy) GetInitialBackoffMs() uint32 {
+	if x != nil {
+		return x.InitialBackoffMs
+	}
+	return 0
+}
+
+func (x *RetryPolicy) GetMaxBackoffMs() uint32 {
+	if x != nil {
+		return x.MaxBackoffMs
+	}
+	return 0
+}
+
+func (x *RetryPolicy) GetRetryableCodes() []string {
+	if x != nil {
+		return x.RetryableCodes
+	}
+	return nil
+}
+
+// 限流
+type RateLimit struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// 每秒允许的请求数, 0 表示不限制
+	Qps uint32 `protobuf:"varint,1,opt,name=qps,proto3" json:"qps,omitempty"`
+	// 允许的突发请求数, 默认等于 qps
+	Burst         uint32 `protobuf:"varint,2,opt,name=burst,proto3" json:"burst,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RateLimit) Reset() {
+	*x = RateLimit{}
+	mi := &file_zapp_grpc_options_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RateLimit) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RateLimit) ProtoMessage() {}
+
+func (x *RateLimit) ProtoReflect() protoreflect.Message {
+	mi := &file_zapp_grpc_options_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RateLimit.ProtoReflect.Descriptor instead.
+func (*RateLimit) Descriptor() ([]byte, []int) {
+	return file_zapp_grpc_options_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *RateLimit) GetQps() uint32 {
+	if x != nil {
+		return x.Qps
+	}
+	return 0
+}
+
+func (x *RateLimit) GetBurst() uint32 {
+	if x != nil {
+		return x.Burst
+	}
+	return 0
+}
+
+var file_zapp_grpc_options_proto_extTypes = []protoimpl.ExtensionInfo{
+	{
+		ExtendedType:  (*descriptorpb.ServiceOptions)(nil),
+		ExtensionType: (*ServiceOptions)(nil),
+		Field:         51800,
+		Name:          "zapp.grpc.service",
+		Tag:           "bytes,51800,opt,name=service",
+		Filename:      "zapp/grpc/options.proto",
+	},
+	{
+		ExtendedType:  (*descriptorpb.MethodOptions)(nil),
+		ExtensionType: (*MethodOptions)(nil),
+		Field:         51800,
+		Name:          "zapp.grpc.method",
+		Tag:           "bytes,51800,opt,name=method",
+		Filename:      "zapp/grpc/options.proto",
+	},
+}
+
+// Extension fields to descriptorpb.ServiceOptions.
+var (
+	// optional zapp.grpc.ServiceOptions service = 51800;
+	E_Service = &file_zapp_grpc_options_proto_extTypes[0]
+)
+
+// Extension fields to descriptorpb.MethodOptions.
+var (
+	// optional zapp.grpc.MethodOptions method = 51800;
+	E_Method = &file_zapp_grpc_options_proto_extTypes[1]
+)
+
+var File_zapp_grpc_options_proto protoreflect.FileDescriptor
+
+const file_zapp_grpc_options_proto_rawDesc = "" +
+	"\n" +
+	"\x17zapp/grpc/options.proto\x12\tzapp.grpc\x1a google/protobuf/descriptor.proto\"1\n" +
+	"\x0eServiceOptions\x12\x1f\n" +
+	"\vserver_name\x18\x01 \x01(\tR\n" +
+	"serverName\"\xbb\x02\n" +
+	"\rMethodOptions\x12\x1d\n" +
+	"\n" +
+	"timeout_ms\x18\x01 \x01(\rR\ttimeoutMs\x12\x1e\n" +
+	"\n" +
+	"idempotent\x18\x02 \x01(\bR\n" +
+	"idempotent\x12,\n" +
+	"\x05retry\x18\x03 \x01(\v2\x16.zapp.grpc.RetryPolicyR\x05retry\x12#\n" +
+	"\rauth_required\x18\x04 \x01(\bR\fauthRequired\x123\n" +
+	"\n" +
+	"rate_limit\x18\x05 \x01(\v2\x14.zapp.grpc.RateLimitR\trateLimit\x12\x1c\n" +
+	"\tcacheable\x18\x06 \x01(\bR\tcacheable\x12 \n" +
+	"\fcache_ttl_ms\x18\a \x01(\rR\n" +
+	"cacheTtlMs\x12#\n" +
+	"\rredact_fields\x18\b \x03(\tR\fredactFields\"\xad\x01\n" +
+	"\vRetryPolicy\x12!\n" +
+	"\fmax_attempts\x18\x01 \x01(\rR\vmaxAttempts\x12,\n" +
+	"\x12initial_backoff_ms\x18\x02 \x01(\rR\x10initialBackoffMs\x12$\n" +
+	"\x0emax_backoff_ms\x18\x03 \x01(\rR\fmaxBackoffMs\x12'\n" +
+	"\x0fretryable_codes\x18\x04 \x03(\tR\x0eretryableCodes\"3\n" +
+	"\tRateLimit\x12\x10\n" +
+	"\x03qps\x18\x01 \x01(\rR\x03qps\x12\x14\n" +
+	"\x05burst\x18\x02 \x01(\rR\x05burst:V\n" +
+	"\aservice\x12\x1f.google.protobuf.ServiceOptions\x18ؔ\x03 \x01(\v2\x19.zapp.grpc.ServiceOptionsR\aservice:R\n" +
+	"\x06method\x12\x1e.google.protobuf.MethodOptions\x18ؔ\x03 \x01(\v2\x18.zapp.grpc.MethodOptionsR\x06methodB)Z'github.com/zly-app/grpc/options;optionsb\x06proto3"
+
+var (
+	file_zapp_grpc_options_proto_rawDescOnce sync.Once
+	file_zapp_grpc_options_proto_rawDescData []byte
+)
+
+func file_zapp_grpc_options_proto_rawDescGZIP() []byte {
+	file_zapp_grpc_options_proto_rawDescOnce.Do(func() {
+		file_zapp_grpc_options_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_zapp_grpc_options_proto_rawDesc), len(file_zapp_grpc_options_proto_rawDesc)))
+	})
+	return file_zapp_grpc_options_proto_rawDescData
+}
+
+var file_zapp_grpc_options_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_zapp_grpc_options_proto_goTypes = []any{
+	(*ServiceOptions)(nil),              // 0: zapp.grpc.ServiceOptions
+	(*MethodOptions)(nil),               // 1: zapp.grpc.MethodOptions
+	(*RetryPolicy)(nil),                 // 2: zapp.grpc.RetryPolicy
+	(*RateLimit)(nil),                   // 3: zapp.grpc.RateLimit
+	(*descriptorpb.ServiceOptions)(nil), // 4: google.protobuf.ServiceOptions
+	(*descriptorpb.MethodOptions)(nil),  // 5: google.protobuf.MethodOptions
+}
+var file_zapp_grpc_options_proto_depIdxs = []int32{
+	2, // 0: zapp.grpc.MethodOptions.retry:type_name -> zapp.grpc.RetryPolicy
+	3, // 1: zapp.grpc.MethodOptions.rate_limit:type_name -> zapp.grpc.RateLimit
+	4, // 2: zapp.grpc.service:extendee -> google.protobuf.ServiceOptions
+	5, // 3: zapp.grpc.method:extendee -> google.protobuf.MethodOptions
+	0, // 4: zapp.grpc.service:type_name -> zapp.grpc.ServiceOptions
+	1, // 5: zapp.grpc.method:type_name -> zapp.grpc.MethodOptions
+	6, // [6:6] is the sub-list for method output_type
+	6, // [6:6] is the sub-list for method input_type
+	4, // [4:6] is the sub-list for extension type_name
+	2, // [2:4] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_zapp_grpc_options_proto_init() }
+func file_zapp_grpc_options_proto_init() {
+	if File_zapp_grpc_options_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_zapp_grpc_options_proto_rawDesc), len(file_zapp_grpc_options_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   4,
+			NumExtensions: 2,
+			NumServices:   0,
+		},
+		GoTypes:           file_zapp_grpc_options_proto_goTypes,
+		DependencyIndexes: file_zapp_grpc_options_proto_depIdxs,
+		MessageInfos:      file_zapp_grpc_options_proto_msgTypes,
+		ExtensionInfos:    file_zapp_grpc_options_proto_extTypes,
+	}.Build()
+	File_zapp_grpc_options_proto = out.File
+	file_zapp_grpc_options_proto_goTypes = nil
+	file_zapp_grpc_options_proto_depIdxs = nil
+}
@@ -0,0 +1,118 @@
+package pkg
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// 业务错误码在 ErrorInfo 中的域
+	BizCodeDomain = "zapp.biz"
+
+	bizCodeMetaKey       = "code"
+	bizHttpStatusMetaKey = "http_status"
+)
+
+// 业务错误码
+type BizCode struct {
+	Code       int32      // 业务错误码
+	Reason     string     // 错误原因, 如 USER_NOT_FOUND
+	Message    string     // 默认错误消息
+	GrpcCode   codes.Code // 对应的grpc错误码
+	HttpStatus int        // 对应的http状态码
+}
+
+var (
+	bizCodes   = map[int32]*BizCode{}
+	bizCodesMx sync.RWMutex
+)
+
+// 注册业务错误码. httpStatus 为 0 时根据 grpcCode 自动推导. 重复注册会 panic
+func RegisterBizCode(code int32, reason, message string, grpcCode codes.Code, httpStatus int) *BizCode {
+	if httpStatus == 0 {
+		httpStatus = runtime.HTTPStatusFromCode(grpcCode)
+	}
+	b := &BizCode{
+		Code:       code,
+		Reason:     reason,
+		Message:    message,
+		GrpcCode:   grpcCode,
+		HttpStatus: httpStatus,
+	}
+
+	bizCodesMx.Lock()
+	defer bizCodesMx.Unlock()
+	if _, ok := bizCodes[code]; ok {
+		panic(fmt.Sprintf("业务错误码重复注册: %d", code))
+	}
+	bizCodes[code] = b
+	return b
+}
+
+// 获取已注册的业务错误码
+func GetBizCode(code int32) (*BizCode, bool) {
+	bizCodesMx.RLock()
+	defer bizCodesMx.RUnlock()
+	b, ok := bizCodes[code]
+	return b, ok
+}
+
+// 生成错误, 使用默认错误消息
+func (b *BizCode) Err() error {
+	return b.WithMessage(b.Message)
+}
+
+// 生成错误, 使用指定的错误消息
+func (b *BizCode) WithMessage(msg string) error {
+	err := status.New(b.GrpcCode, msg).Err()
+	return WithErrorInfo(err, b.Reason, BizCodeDomain, map[string]string{
+		bizCodeMetaKey:       strconv.Itoa(int(b.Code)),
+		bizHttpStatusMetaKey: strconv.Itoa(b.HttpStatus),
+	})
+}
+
+// 生成错误, 使用格式化的错误消息
+func (b *BizCode) Errorf(format string, a ...interface{}) error {
+	return b.WithMessage(fmt.Sprintf(format, a...))
+}
+
+// 从err中提取业务错误码. 即使本地没有注册该业务错误码, 也会根据 ErrorInfo 中携带的数据还原
+func GetBizCodeByErr(err error) (*BizCode, bool) {
+	for _, d := range GetErrDetails(err) {
+		if b, ok := parseBizCodeErrorInfo(d); ok {
+			b.GrpcCode = ErrToStatus(err).Code()
+			return b, true
+		}
+	}
+	return nil, false
+}
+
+// 解析 ErrorInfo 中的业务错误码
+func parseBizCodeErrorInfo(detail any) (*BizCode, bool) {
+	info, ok := detail.(interface {
+		GetReason() string
+		GetDomain() string
+		GetMetadata() map[string]string
+	})
+	if !ok || info.GetDomain() != BizCodeDomain {
+		return nil, false
+	}
+	code, err := strconv.Atoi(info.GetMetadata()[bizCodeMetaKey])
+	if err != nil {
+		return nil, false
+	}
+
+	b := &BizCode{Code: int32(code), Reason: info.GetReason()}
+	if reg, ok := GetBizCode(b.Code); ok {
+		*b = *reg
+	}
+	if httpStatus, err := strconv.Atoi(info.GetMetadata()[bizHttpStatusMetaKey]); err == nil && httpStatus > 0 {
+		b.HttpStatus = httpStatus
+	}
+	return b, true
+}
@@ -0,0 +1,162 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bytedance/sonic"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// 二进制元数据key的后缀, grpc会自动对值进行base64编解码
+const BinaryMDataSuffix = "-bin"
+
+// 上下文值的编解码器
+type Codec[T any] interface {
+	Encode(v T) ([]byte, error)
+	Decode(data []byte) (T, error)
+	// 是否为二进制编码, 二进制编码使用 -bin 元数据传输
+	Binary() bool
+}
+
+// 字符串编解码器, 值只能包含可打印的ascii字符
+type StringCodec struct{}
+
+func (StringCodec) Encode(v string) ([]byte, error)    { return []byte(v), nil }
+func (StringCodec) Decode(data []byte) (string, error) { return string(data), nil }
+func (StringCodec) Binary() bool                       { return false }
+
+// 整数编解码器
+type IntCodec struct{}
+
+func (IntCodec) Encode(v int64) ([]byte, error) { return strconv.AppendInt(nil, v, 10), nil }
+func (IntCodec) Decode(data []byte) (int64, error) {
+	return strconv.ParseInt(string(data), 10, 64)
+}
+func (IntCodec) Binary() bool { return false }
+
+// json编解码器, 使用二进制元数据传输
+type JSONCodec[T any] struct{}
+
+func (JSONCodec[T]) Encode(v T) ([]byte, error) { return sonic.Marshal(v) }
+func (JSONCodec[T]) Decode(data []byte) (T, error) {
+	var v T
+	err := sonic.Unmarshal(data, &v)
+	return v, err
+}
+func (JSONCodec[T]) Binary() bool { return true }
+
+// proto编解码器, 使用二进制元数据传输
+type ProtoCodec[T proto.Message] struct{}
+
+func (ProtoCodec[T]) Encode(v T) ([]byte, error) { return proto.Marshal(v) }
+func (ProtoCodec[T]) Decode(data []byte) (T, error) {
+	var zero T
+	v := zero.ProtoReflect().New().Interface().(T)
+	err := proto.Unmarshal(data, v)
+	return v, err
+}
+func (ProtoCodec[T]) Binary() bool { return true }
+
+// 类型化的上下文key, 值通过元数据在客户端和服务端之间传递
+type ContextKey[T any] struct {
+	name     string
+	codec    Codec[T]
+	validate func(v T) error
+}
+
+/*
+定义类型化的上下文key.
+
+	name 元数据key, 只能包含小写字母, 数字, '-', '_', '.'. 二进制编码会自动加上 -bin 后缀
+	codec 编解码器, 如 StringCodec{}, IntCodec{}, JSONCodec[T]{}, ProtoCodec[T]{}
+*/
+func DefineContextKey[T any](name string, codec Codec[T]) *ContextKey[T] {
+	name = strings.ToLower(name)
+	if name == "" || strings.HasPrefix(name, "grpc-") {
+		panic(fmt.Sprintf("无效的上下文key: %q", name))
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			panic(fmt.Sprintf("无效的上下文key: %q", name))
+		}
+	}
+	if codec.Binary() && !strings.HasSuffix(name, BinaryMDataSuffix) {
+		name += BinaryMDataSuffix
+	}
+	if !codec.Binary() && strings.HasSuffix(name, BinaryMDataSuffix) {
+		panic(fmt.Sprintf("文本编解码器不能使用 %s 后缀: %q", BinaryMDataSuffix, name))
+	}
+	return &ContextKey[T]{name: name, codec: codec}
+}
+
+// 设置校验函数, 服务端提取值时会校验. 如果值实现了 Validate() error 也会被调用
+func (k *ContextKey[T]) WithValidate(fn func(v T) error) *ContextKey[T] {
+	k.validate = fn
+	return k
+}
+
+// 元数据key
+func (k *ContextKey[T]) Name() string {
+	return k.name
+}
+
+// 客户端注入值, 使用返回的ctx请求下游时会带上
+func (k *ContextKey[T]) Inject(ctx context.Context, v T) (context.Context, error) {
+	data, err := k.codec.Encode(v)
+	if err != nil {
+		return ctx, fmt.Errorf("编码元数据 %s 失败: %v", k.name, err)
+	}
+	if !k.codec.Binary() {
+		for _, c := range data {
+			if c < 0x20 || c > 0x7e {
+				return ctx, fmt.Errorf("元数据 %s 的值包含不可打印的字符, 请使用二进制编解码器", k.name)
+			}
+		}
+	}
+
+	mdOut, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		mdOut = mdOut.Copy()
+	} else {
+		mdOut = metadata.New(nil)
+	}
+	mdOut.Set(k.name, string(data))
+	return metadata.NewOutgoingContext(ctx, mdOut), nil
+}
+
+/*
+服务端提取上游传入的值.
+
+	值不存在时返回 false.
+	值解码或校验失败时返回 InvalidArgument 状态的错误, 可以直接返回给调用方
+*/
+func (k *ContextKey[T]) Extract(ctx context.Context) (T, bool, error) {
+	var zero T
+	mdIn, _ := metadata.FromIncomingContext(ctx)
+	vs := mdIn.Get(k.name)
+	if len(vs) == 0 {
+		return zero, false, nil
+	}
+
+	v, err := k.codec.Decode([]byte(vs[0]))
+	if err != nil {
+		return zero, true, status.Errorf(codes.InvalidArgument, "元数据 %s 解码失败: %v", k.name, err)
+	}
+	if vi, ok := any(v).(interface{ Validate() error }); ok {
+		if err = vi.Validate(); err != nil {
+			return zero, true, status.Errorf(codes.InvalidArgument, "元数据 %s 校验失败: %v", k.name, err)
+		}
+	}
+	if k.validate != nil {
+		if err = k.validate(v); err != nil {
+			return zero, true, status.Errorf(codes.InvalidArgument, "元数据 %s 校验失败: %v", k.name, err)
+		}
+	}
+	return v, true, nil
+}
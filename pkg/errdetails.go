@@ -0,0 +1,130 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+type FieldViolation = errdetails.BadRequest_FieldViolation
+type QuotaViolation = errdetails.QuotaFailure_Violation
+
+// 将err转为status, 支持被包装的status错误. 非status错误视为 Unknown
+func ErrToStatus(err error) *status.Status {
+	if err == nil {
+		return status.New(codes.OK, "")
+	}
+	var se interface {
+		GRPCStatus() *status.Status
+	}
+	if errors.As(err, &se) {
+		if st := se.GRPCStatus(); st != nil {
+			return st
+		}
+	}
+	return status.New(codes.Unknown, err.Error())
+}
+
+// 为err附加详情, 原有详情会保留. 如果err为nil则返回nil
+func WithErrDetails(err error, details ...protoadapt.MessageV1) error {
+	if err == nil || len(details) == 0 {
+		return err
+	}
+	st, e := ErrToStatus(err).WithDetails(details...)
+	if e != nil {
+		return err
+	}
+	return st.Err()
+}
+
+// 附加请求参数错误详情
+func WithBadRequest(err error, violations ...*FieldViolation) error {
+	return WithErrDetails(err, &errdetails.BadRequest{FieldViolations: violations})
+}
+
+// 附加错误信息详情, reason 为错误原因, domain 为错误所属的域
+func WithErrorInfo(err error, reason, domain string, metadata map[string]string) error {
+	return WithErrDetails(err, &errdetails.ErrorInfo{Reason: reason, Domain: domain, Metadata: metadata})
+}
+
+// 附加重试信息详情, 告知调用方多久之后重试
+func WithRetryInfo(err error, retryDelay time.Duration) error {
+	return WithErrDetails(err, &errdetails.RetryInfo{RetryDelay: durationpb.New(retryDelay)})
+}
+
+// 附加配额不足详情
+func WithQuotaFailure(err error, violations ...*QuotaViolation) error {
+	return WithErrDetails(err, &errdetails.QuotaFailure{Violations: violations})
+}
+
+// 附加本地化消息详情, locale 如 zh-CN, en-US
+func WithLocalizedMessage(err error, locale, message string) error {
+	return WithErrDetails(err, &errdetails.LocalizedMessage{Locale: locale, Message: message})
+}
+
+// 获取err的所有详情
+func GetErrDetails(err error) []any {
+	if err == nil {
+		return nil
+	}
+	return ErrToStatus(err).Details()
+}
+
+// 获取err中第一个指定类型的详情
+func getErrDetail[T any](err error) (T, bool) {
+	for _, d := range GetErrDetails(err) {
+		if v, ok := d.(T); ok {
+			return v, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// 获取请求参数错误详情
+func GetBadRequest(err error) (*errdetails.BadRequest, bool) {
+	return getErrDetail[*errdetails.BadRequest](err)
+}
+
+// 获取错误信息详情
+func GetErrorInfo(err error) (*errdetails.ErrorInfo, bool) {
+	return getErrDetail[*errdetails.ErrorInfo](err)
+}
+
+// 获取重试信息详情
+func GetRetryInfo(err error) (*errdetails.RetryInfo, bool) {
+	return getErrDetail[*errdetails.RetryInfo](err)
+}
+
+// 获取配额不足详情
+func GetQuotaFailure(err error) (*errdetails.QuotaFailure, bool) {
+	return getErrDetail[*errdetails.QuotaFailure](err)
+}
+
+// 获取本地化消息详情
+func GetLocalizedMessage(err error) (*errdetails.LocalizedMessage, bool) {
+	return getErrDetail[*errdetails.LocalizedMessage](err)
+}
+
+// 解析错误码, 支持名称(忽略大小写和下划线)或数字, 如 Unknown, Internal, 13
+func ParseCode(s string) (codes.Code, error) {
+	s = strings.TrimSpace(s)
+	if v, err := strconv.ParseUint(s, 10, 32); err == nil {
+		return codes.Code(v), nil
+	}
+	name := strings.ToLower(strings.ReplaceAll(s, "_", ""))
+	for c := codes.OK; c <= codes.Unauthenticated; c++ {
+		if strings.ToLower(c.String()) == name {
+			return c, nil
+		}
+	}
+	return 0, fmt.Errorf("无效的错误码: %s", s)
+}
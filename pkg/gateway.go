@@ -2,10 +2,14 @@ package pkg
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"sync"
+	"unsafe"
 
 	"github.com/bytedance/sonic"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protowire"
 )
 
 const (
@@ -13,28 +17,233 @@ const (
 )
 
 type GatewayData struct {
-	Method   string
-	Path     string
-	RawQuery string
-	RawBody  string
-	IP       string
-	Headers  http.Header
+	Method    string
+	Path      string
+	RawQuery  string
+	RawBody   string
+	IP        string
+	Headers   http.Header
+	RequestId string
+}
+
+/*
+网关数据的proto编码, 字段编号如下, 和下面的proto定义兼容
+
+	message GatewayData {
+		string method = 1;
+		string path = 2;
+		string raw_query = 3;
+		bytes raw_body = 4;
+		string ip = 5;
+		repeated Header headers = 6;
+		string request_id = 7;
+	}
+	message Header {
+		string key = 1;
+		repeated string values = 2;
+	}
+*/
+const (
+	gatewayDataMethod protowire.Number = iota + 1
+	gatewayDataPath
+	gatewayDataRawQuery
+	gatewayDataRawBody
+	gatewayDataIP
+	gatewayDataHeaders
+	gatewayDataRequestId
+)
+
+const (
+	gatewayHeaderKey protowire.Number = iota + 1
+	gatewayHeaderValues
+)
+
+// 将网关数据编码为proto
+func MarshalGatewayData(d *GatewayData) []byte {
+	size := sizeGatewayString(gatewayDataMethod, d.Method) + sizeGatewayString(gatewayDataPath, d.Path) +
+		sizeGatewayString(gatewayDataRawQuery, d.RawQuery) + sizeGatewayString(gatewayDataRawBody, d.RawBody) +
+		sizeGatewayString(gatewayDataIP, d.IP) + sizeGatewayString(gatewayDataRequestId, d.RequestId)
+	for k, vs := range d.Headers {
+		n := sizeGatewayHeader(k, vs)
+		size += protowire.SizeTag(gatewayDataHeaders) + protowire.SizeBytes(n)
+	}
+
+	b := make([]byte, 0, size)
+	b = appendGatewayString(b, gatewayDataMethod, d.Method)
+	b = appendGatewayString(b, gatewayDataPath, d.Path)
+	b = appendGatewayString(b, gatewayDataRawQuery, d.RawQuery)
+	b = appendGatewayString(b, gatewayDataRawBody, d.RawBody)
+	b = appendGatewayString(b, gatewayDataIP, d.IP)
+	for k, vs := range d.Headers {
+		b = protowire.AppendTag(b, gatewayDataHeaders, protowire.BytesType)
+		b = protowire.AppendVarint(b, uint64(sizeGatewayHeader(k, vs)))
+		b = appendGatewayString(b, gatewayHeaderKey, k)
+		for _, v := range vs {
+			b = protowire.AppendTag(b, gatewayHeaderValues, protowire.BytesType)
+			b = protowire.AppendString(b, v)
+		}
+	}
+	b = appendGatewayString(b, gatewayDataRequestId, d.RequestId)
+	return b
+}
+
+// 解码网关数据, 兼容旧版本网关的json格式. 解码后的字符串引用 data 的内存, 不会复制
+func UnmarshalGatewayData(data string, d *GatewayData) error {
+	if len(data) == 0 {
+		return nil
+	}
+	if data[0] == '{' { // json格式, proto编码不会使用该字节开头
+		return sonic.UnmarshalString(data, d)
+	}
+
+	b := unsafe.Slice(unsafe.StringData(data), len(data))
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return fmt.Errorf("解码网关数据失败: %v", protowire.ParseError(n))
+		}
+		b = b[n:]
+		if typ != protowire.BytesType || num < gatewayDataMethod || num > gatewayDataRequestId {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return fmt.Errorf("解码网关数据失败: %v", protowire.ParseError(n))
+			}
+			b = b[n:]
+			continue
+		}
+
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return fmt.Errorf("解码网关数据失败: %v", protowire.ParseError(n))
+		}
+		b = b[n:]
+		switch num {
+		case gatewayDataMethod:
+			d.Method = bytesToString(v)
+		case gatewayDataPath:
+			d.Path = bytesToString(v)
+		case gatewayDataRawQuery:
+			d.RawQuery = bytesToString(v)
+		case gatewayDataRawBody:
+			d.RawBody = bytesToString(v)
+		case gatewayDataIP:
+			d.IP = bytesToString(v)
+		case gatewayDataHeaders:
+			if d.Headers == nil {
+				d.Headers = make(http.Header)
+			}
+			if err := unmarshalGatewayHeader(v, d.Headers); err != nil {
+				return err
+			}
+		case gatewayDataRequestId:
+			d.RequestId = bytesToString(v)
+		}
+	}
+	return nil
+}
+
+func unmarshalGatewayHeader(b []byte, h http.Header) error {
+	var key string
+	var values []string
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return fmt.Errorf("解码网关数据的header失败: %v", protowire.ParseError(n))
+		}
+		b = b[n:]
+		if typ != protowire.BytesType || (num != gatewayHeaderKey && num != gatewayHeaderValues) {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return fmt.Errorf("解码网关数据的header失败: %v", protowire.ParseError(n))
+			}
+			b = b[n:]
+			continue
+		}
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return fmt.Errorf("解码网关数据的header失败: %v", protowire.ParseError(n))
+		}
+		b = b[n:]
+		if num == gatewayHeaderKey {
+			key = bytesToString(v)
+		} else {
+			values = append(values, bytesToString(v))
+		}
+	}
+	h[key] = append(h[key], values...)
+	return nil
+}
+
+func sizeGatewayHeader(key string, values []string) int {
+	n := sizeGatewayString(gatewayHeaderKey, key)
+	for _, v := range values {
+		n += protowire.SizeTag(gatewayHeaderValues) + protowire.SizeBytes(len(v))
+	}
+	return n
+}
+
+func sizeGatewayString(num protowire.Number, s string) int {
+	if s == "" {
+		return 0
+	}
+	return protowire.SizeTag(num) + protowire.SizeBytes(len(s))
+}
+
+func appendGatewayString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func bytesToString(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	return unsafe.String(unsafe.SliceData(b), len(b))
 }
 
 type gatewayDataKey struct{}
 
-// 获取网关数据
+// 延迟解码的网关数据, 首次获取时解码
+type lazyGatewayData struct {
+	raw  string
+	once sync.Once
+	data *GatewayData
+}
+
+func (l *lazyGatewayData) get() *GatewayData {
+	l.once.Do(func() {
+		l.data = &GatewayData{}
+		_ = UnmarshalGatewayData(l.raw, l.data)
+	})
+	return l.data
+}
+
+// 服务端保存上游网关传入的网关数据, 不会立即解码, 首次调用 GetGatewayDataByIncoming 时解码
+func ExtractGatewayData(ctx context.Context, mdIn metadata.MD) context.Context {
+	s := mdIn[GatewayMDataKey]
+	if len(s) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, gatewayDataKey{}, &lazyGatewayData{raw: s[0]})
+}
+
+// 获取网关数据, 多次获取返回同一个对象, 不要修改
 func GetGatewayDataByIncoming(ctx context.Context) *GatewayData {
-	tmp := ctx.Value(gatewayDataKey{})
-	if gd, ok := tmp.(*GatewayData); ok {
-		return gd
+	switch v := ctx.Value(gatewayDataKey{}).(type) {
+	case *GatewayData:
+		return v
+	case *lazyGatewayData:
+		return v.get()
 	}
 
 	ret := &GatewayData{}
 	mdIn, _ := metadata.FromIncomingContext(ctx)
 	s, _ := mdIn[GatewayMDataKey]
 	if len(s) > 0 {
-		_ = sonic.UnmarshalString(s[0], ret)
+		_ = UnmarshalGatewayData(s[0], ret)
 	}
 	return ret
 }
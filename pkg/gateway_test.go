@@ -0,0 +1,173 @@
+package pkg
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bytedance/sonic"
+	"google.golang.org/grpc/metadata"
+)
+
+func makeBenchGatewayData() *GatewayData {
+	return &GatewayData{
+		Method:   http.MethodPost,
+		Path:     "/hello/say",
+		RawQuery: "a=1&b=2",
+		RawBody:  `{"msg":"` + strings.Repeat("hello", 200) + `"}`,
+		IP:       "127.0.0.1",
+		Headers: http.Header{
+			"Accept":          {"application/json"},
+			"Accept-Language": {"zh-CN,zh;q=0.9,en;q=0.8"},
+			"Content-Type":    {"application/json"},
+			"User-Agent":      {"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko)"},
+			"X-Request-Id":    {"0123456789abcdef0123456789abcdef"},
+		},
+		RequestId: "0123456789abcdef0123456789abcdef",
+	}
+}
+
+func TestGatewayData(t *testing.T) {
+	d := makeBenchGatewayData()
+	d.Headers["Cookie"] = []string{"a=1", "b=2"}
+	d.Headers["X-Empty"] = []string{""}
+
+	t.Run("proto", func(t *testing.T) {
+		got := &GatewayData{}
+		if err := UnmarshalGatewayData(string(MarshalGatewayData(d)), got); err != nil {
+			t.Fatalf("解码失败: %v", err)
+		}
+		if !reflect.DeepEqual(got, d) {
+			t.Fatalf("解码结果不一致: %+v", got)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		s, _ := sonic.MarshalString(d)
+		got := &GatewayData{}
+		if err := UnmarshalGatewayData(s, got); err != nil {
+			t.Fatalf("解码json失败: %v", err)
+		}
+		if !reflect.DeepEqual(got, d) {
+			t.Fatalf("解码json结果不一致: %+v", got)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		got := &GatewayData{}
+		if err := UnmarshalGatewayData(string(MarshalGatewayData(&GatewayData{})), got); err != nil {
+			t.Fatalf("解码失败: %v", err)
+		}
+		if !reflect.DeepEqual(got, &GatewayData{}) {
+			t.Fatalf("解码结果不一致: %+v", got)
+		}
+	})
+
+	t.Run("incoming", func(t *testing.T) {
+		md := metadata.MD{GatewayMDataKey: []string{string(MarshalGatewayData(d))}}
+		ctx := ExtractGatewayData(context.Background(), md)
+		got := GetGatewayDataByIncoming(ctx)
+		if !reflect.DeepEqual(got, d) {
+			t.Fatalf("解码结果不一致: %+v", got)
+		}
+		if GetGatewayDataByIncoming(ctx) != got {
+			t.Fatal("多次获取应返回同一个对象")
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		if err := UnmarshalGatewayData("\x0a\x05ab", &GatewayData{}); err == nil {
+			t.Fatal("截断的数据应解码失败")
+		}
+	})
+}
+
+func BenchmarkGatewayDataMarshalJSON(b *testing.B) {
+	d := makeBenchGatewayData()
+	s, _ := sonic.MarshalString(d)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = sonic.MarshalString(d)
+	}
+	b.ReportMetric(float64(len(s)), "bytes/op") // ResetTimer 会清除之前报告的指标
+}
+
+func BenchmarkGatewayDataMarshalProto(b *testing.B) {
+	d := makeBenchGatewayData()
+	size := len(MarshalGatewayData(d))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = MarshalGatewayData(d)
+	}
+	b.ReportMetric(float64(size), "bytes/op")
+}
+
+func BenchmarkGatewayDataUnmarshalJSON(b *testing.B) {
+	s, _ := sonic.MarshalString(makeBenchGatewayData())
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d := &GatewayData{}
+		if err := UnmarshalGatewayData(s, d); err != nil || d.Headers.Get("Accept-Language") == "" {
+			b.Fatalf("解码失败: %v", err)
+		}
+	}
+}
+
+func BenchmarkGatewayDataUnmarshalProto(b *testing.B) {
+	s := string(MarshalGatewayData(makeBenchGatewayData()))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d := &GatewayData{}
+		if err := UnmarshalGatewayData(s, d); err != nil || d.Headers.Get("Accept-Language") == "" {
+			b.Fatalf("解码失败: %v", err)
+		}
+	}
+}
+
+// 服务端请求中未获取网关数据时的开销
+func BenchmarkGatewayDataExtractLazy(b *testing.B) {
+	md := metadata.MD{GatewayMDataKey: []string{string(MarshalGatewayData(makeBenchGatewayData()))}}
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = ExtractGatewayData(ctx, md)
+	}
+}
+
+// 服务端请求中多次获取网关数据的开销
+func BenchmarkGatewayDataGetLazy(b *testing.B) {
+	md := metadata.MD{GatewayMDataKey: []string{string(MarshalGatewayData(makeBenchGatewayData()))}}
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c := ExtractGatewayData(ctx, md)
+		for j := 0; j < 3; j++ {
+			if GetGatewayDataByIncoming(c).RequestId == "" {
+				b.Fatal("解码失败")
+			}
+		}
+	}
+}
+
+// 旧版本每次获取都解码json
+func BenchmarkGatewayDataGetJSON(b *testing.B) {
+	s, _ := sonic.MarshalString(makeBenchGatewayData())
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.MD{GatewayMDataKey: []string{s}})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < 3; j++ {
+			if GetGatewayDataByIncoming(ctx).RequestId == "" {
+				b.Fatal("解码失败")
+			}
+		}
+	}
+}
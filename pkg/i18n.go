@@ -0,0 +1,165 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bytedance/sonic"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// 调用方直接传入语言的元数据key
+	LocaleMDataKey = "accept-language"
+	// grpc-gateway 转发 Accept-Language 时使用的元数据key
+	gatewayLocaleMDataKey = "grpcgateway-accept-language"
+	// Accept-Language header
+	AcceptLanguageHeader = "Accept-Language"
+)
+
+// 消息目录, locale -> key -> message
+var (
+	messageCatalogs   = map[string]map[string]string{}
+	messageCatalogsMx sync.RWMutex
+)
+
+// 注册消息目录. key 为业务错误码的 Reason 或 Code, 同一个 locale 多次注册会合并
+func RegisterMessageCatalog(locale string, messages map[string]string) {
+	locale = normalizeLocale(locale)
+	messageCatalogsMx.Lock()
+	defer messageCatalogsMx.Unlock()
+
+	c, ok := messageCatalogs[locale]
+	if !ok {
+		c = make(map[string]string, len(messages))
+		messageCatalogs[locale] = c
+	}
+	for k, v := range messages {
+		c[k] = v
+	}
+}
+
+// 从文件系统加载消息目录, 目录下每个 json 文件为一个 locale, 如 zh-CN.json, en-US.json. 可以配合 embed.FS 使用
+func LoadMessageCatalogFS(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return fmt.Errorf("读取消息目录失败: %v", err)
+	}
+	for _, e := range entries {
+		if e.IsDir() || path.Ext(e.Name()) != ".json" {
+			continue
+		}
+		bs, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return fmt.Errorf("读取消息目录文件失败: %s: %v", e.Name(), err)
+		}
+		messages := make(map[string]string)
+		if err = sonic.Unmarshal(bs, &messages); err != nil {
+			return fmt.Errorf("解析消息目录文件失败: %s: %v", e.Name(), err)
+		}
+		RegisterMessageCatalog(strings.TrimSuffix(e.Name(), ".json"), messages)
+	}
+	return nil
+}
+
+// 根据语言列表查找消息, 先精确匹配 locale, 再匹配其主语言. 返回命中的 locale
+func TranslateMessage(locales []string, keys ...string) (string, string, bool) {
+	messageCatalogsMx.RLock()
+	defer messageCatalogsMx.RUnlock()
+
+	for _, locale := range locales {
+		locale = normalizeLocale(locale)
+		candidates := []string{locale}
+		if k := strings.IndexByte(locale, '-'); k != -1 {
+			candidates = append(candidates, locale[:k])
+		}
+		for _, l := range candidates {
+			c, ok := messageCatalogs[l]
+			if !ok {
+				continue
+			}
+			for _, key := range keys {
+				if msg, ok := c[key]; ok {
+					return msg, l, true
+				}
+			}
+		}
+	}
+	return "", "", false
+}
+
+// 翻译业务错误码的消息
+func TranslateBizCode(locales []string, b *BizCode) (string, string, bool) {
+	return TranslateMessage(locales, b.Reason, strconv.Itoa(int(b.Code)))
+}
+
+// 从请求中获取调用方的语言列表, 按优先级排序
+func GetLocalesByIncoming(ctx context.Context) []string {
+	mdIn, _ := metadata.FromIncomingContext(ctx)
+	for _, key := range []string{LocaleMDataKey, gatewayLocaleMDataKey} {
+		if vs := mdIn.Get(key); len(vs) > 0 && vs[0] != "" {
+			return ParseAcceptLanguage(vs[0])
+		}
+	}
+	if gd := GetGatewayDataByIncoming(ctx); gd.Headers != nil {
+		if v := gd.Headers.Get(AcceptLanguageHeader); v != "" {
+			return ParseAcceptLanguage(v)
+		}
+	}
+	return nil
+}
+
+// 解析 Accept-Language, 如 zh-CN,zh;q=0.9,en;q=0.8
+func ParseAcceptLanguage(s string) []string {
+	type item struct {
+		locale string
+		q      float64
+	}
+	items := make([]item, 0)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		locale, q := part, 1.0
+		if k := strings.IndexByte(part, ';'); k != -1 {
+			locale = strings.TrimSpace(part[:k])
+			param := strings.TrimSpace(part[k+1:])
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if locale == "" || locale == "*" || q <= 0 {
+			continue
+		}
+		items = append(items, item{locale: locale, q: q})
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].q > items[j].q })
+
+	ret := make([]string, len(items))
+	for i, it := range items {
+		ret[i] = it.locale
+	}
+	return ret
+}
+
+// 统一 locale 格式, 如 zh_cn -> zh-CN
+func normalizeLocale(locale string) string {
+	locale = strings.ReplaceAll(strings.TrimSpace(locale), "_", "-")
+	parts := strings.Split(locale, "-")
+	parts[0] = strings.ToLower(parts[0])
+	for i := 1; i < len(parts); i++ {
+		if len(parts[i]) == 2 {
+			parts[i] = strings.ToUpper(parts[i])
+		}
+	}
+	return strings.Join(parts, "-")
+}
@@ -0,0 +1,105 @@
+package pkg
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+
+	"github.com/zly-app/zapp/log"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/metadata"
+)
+
+// 自定义数据key的前缀
+const CustomDataPrefix = "cw_"
+
+// 生成自定义数据在元数据中的key
+func MakeCustomDataKey(key string) string {
+	k := CustomDataPrefix + key
+	return base64.StdEncoding.EncodeToString([]byte(k))
+}
+
+// 自定义数据在元数据中的key前缀, 元数据的key会被转为小写
+var customDataMDataKeyPrefix = strings.ToLower(base64.StdEncoding.EncodeToString([]byte(CustomDataPrefix)))
+
+// 是否为自定义数据在元数据中的key
+func IsCustomDataMDataKey(k string) bool {
+	return strings.HasPrefix(strings.ToLower(k), customDataMDataKeyPrefix)
+}
+
+// 透传数据, key -> values
+type passThroughKey struct{}
+
+type passThroughData map[string][]string
+
+func getPassThroughData(ctx context.Context) passThroughData {
+	d, _ := ctx.Value(passThroughKey{}).(passThroughData)
+	return d
+}
+
+/*
+从上游请求的元数据中提取允许透传的自定义数据存入ctx, 之后使用这个ctx请求下游时会自动带上.
+
+	keys 允许透传的key
+	maxValueSize 单个key的值的最大字节数, 超过时丢弃这个key, 小于1表示不限制
+	maxTotalSize 所有透传数据的最大字节数, 超过后丢弃之后的key, 小于1表示不限制
+*/
+func ExtractPassThroughData(ctx context.Context, mdIn metadata.MD, keys []string, maxValueSize, maxTotalSize int) context.Context {
+	if len(keys) == 0 || len(mdIn) == 0 {
+		return ctx
+	}
+
+	d := make(passThroughData, len(keys))
+	total := 0
+	for _, key := range keys {
+		values := mdIn.Get(MakeCustomDataKey(key))
+		if len(values) == 0 {
+			continue
+		}
+		size := len(key)
+		for _, v := range values {
+			size += len(v)
+		}
+		if maxValueSize > 0 && size-len(key) > maxValueSize {
+			log.Warn(ctx, "透传数据超过大小限制, 已丢弃", zap.String("key", key), zap.Int("size", size-len(key)), zap.Int("maxValueSize", maxValueSize))
+			continue
+		}
+		if maxTotalSize > 0 && total+size > maxTotalSize {
+			log.Warn(ctx, "透传数据总大小超过限制, 已丢弃", zap.String("key", key), zap.Int("maxTotalSize", maxTotalSize))
+			continue
+		}
+		total += size
+		d[key] = values
+	}
+	if len(d) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, passThroughKey{}, d)
+}
+
+// 获取透传数据
+func GetPassThroughData(ctx context.Context, key string) []string {
+	return getPassThroughData(ctx)[key]
+}
+
+// 设置透传数据, 返回的ctx请求下游时会自动带上, 并且会被下游继续透传(如果下游允许这个key)
+func WithPassThroughData(ctx context.Context, key string, values ...string) context.Context {
+	old := getPassThroughData(ctx)
+	d := make(passThroughData, len(old)+1)
+	for k, v := range old {
+		d[k] = v
+	}
+	d[key] = values
+	return context.WithValue(ctx, passThroughKey{}, d)
+}
+
+// 将透传数据写入即将发送的元数据副本中, 已经显式设置的key不会被覆盖
+func InjectPassThroughData(ctx context.Context, mdCopy metadata.MD) {
+	for key, values := range getPassThroughData(ctx) {
+		k := MakeCustomDataKey(key)
+		if len(mdCopy.Get(k)) > 0 {
+			continue
+		}
+		mdCopy.Set(k, values...)
+	}
+}
@@ -0,0 +1,69 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/contrib/propagators/jaeger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// trace传播器
+const (
+	PropagatorTraceContext = "tracecontext" // W3C Trace Context
+	PropagatorBaggage      = "baggage"      // W3C Baggage
+	PropagatorB3           = "b3"           // B3 单header
+	PropagatorB3Multi      = "b3multi"      // B3 多header
+	PropagatorJaeger       = "jaeger"       // Jaeger uber-trace-id
+)
+
+// 根据名称创建组合传播器, 名称为空时返回nil表示使用otel全局传播器
+func NewPropagator(names []string) (propagation.TextMapPropagator, error) {
+	ps := make([]propagation.TextMapPropagator, 0, len(names))
+	for _, name := range names {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case PropagatorTraceContext, "w3c":
+			ps = append(ps, propagation.TraceContext{})
+		case PropagatorBaggage:
+			ps = append(ps, propagation.Baggage{})
+		case PropagatorB3:
+			ps = append(ps, b3.New(b3.WithInjectEncoding(b3.B3SingleHeader)))
+		case PropagatorB3Multi:
+			ps = append(ps, b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader)))
+		case PropagatorJaeger:
+			ps = append(ps, jaeger.Jaeger{})
+		case "":
+		default:
+			return nil, fmt.Errorf("不支持的trace传播器: %s", name)
+		}
+	}
+	if len(ps) == 0 {
+		return nil, nil
+	}
+	return propagation.NewCompositeTextMapPropagator(ps...), nil
+}
+
+type propagatorKey struct{}
+
+// 将传播器存入ctx, 之后的 TraceInjectIn/TraceInjectOut 会使用它. p为nil表示使用otel全局传播器
+func SavePropagator(ctx context.Context, p propagation.TextMapPropagator) context.Context {
+	if p == nil && ctx.Value(propagatorKey{}) == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, propagatorKey{}, propagatorValue{p})
+}
+
+// 获取ctx中的传播器, 不存在时返回otel全局传播器
+func GetPropagator(ctx context.Context) propagation.TextMapPropagator {
+	if v, ok := ctx.Value(propagatorKey{}).(propagatorValue); ok && v.p != nil {
+		return v.p
+	}
+	return otel.GetTextMapPropagator()
+}
+
+type propagatorValue struct {
+	p propagation.TextMapPropagator
+}
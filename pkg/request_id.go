@@ -0,0 +1,67 @@
+package pkg
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// 请求id的http header
+	RequestIdHeader = "X-Request-Id"
+	// 请求id在元数据中的key
+	RequestIdMDataKey = "x-request-id"
+	// 上游传入的请求id的最大长度, 超过时重新生成
+	maxRequestIdLen = 128
+)
+
+type requestIdKey struct{}
+
+// 生成请求id
+func NewRequestId() string {
+	bs := make([]byte, 16)
+	_, _ = rand.Read(bs)
+	return hex.EncodeToString(bs)
+}
+
+// 检查上游传入的请求id是否有效, 只允许可打印的ascii字符
+func IsValidRequestId(id string) bool {
+	if id == "" || len(id) > maxRequestIdLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func SaveRequestId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, id)
+}
+
+// 获取请求id
+func GetRequestId(ctx context.Context) string {
+	id, _ := ctx.Value(requestIdKey{}).(string)
+	return id
+}
+
+// 从上游请求的元数据中提取请求id存入ctx, 不存在或无效时生成一个新的
+func ExtractRequestId(ctx context.Context, mdIn metadata.MD) context.Context {
+	if vs := mdIn.Get(RequestIdMDataKey); len(vs) > 0 && IsValidRequestId(vs[0]) {
+		return SaveRequestId(ctx, vs[0])
+	}
+	return SaveRequestId(ctx, NewRequestId())
+}
+
+// 将请求id写入即将发送的元数据副本中, 已经显式设置的不会被覆盖
+func InjectRequestId(ctx context.Context, mdCopy metadata.MD) {
+	id := GetRequestId(ctx)
+	if id == "" || len(mdCopy.Get(RequestIdMDataKey)) > 0 {
+		return
+	}
+	mdCopy.Set(RequestIdMDataKey, id)
+}
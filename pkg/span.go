@@ -0,0 +1,80 @@
+package pkg
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/zly-app/zapp/config"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// 将 /package.Service/Method 拆分为服务名和方法名
+func SplitMethod(fullMethod string) (string, string) {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	k := strings.LastIndex(fullMethod, "/")
+	if k == -1 {
+		return "unknown", fullMethod
+	}
+	return fullMethod[:k], fullMethod[k+1:]
+}
+
+// 为当前span设置 otel rpc 语义属性
+func SetRpcSpanAttributes(ctx context.Context, fullMethod string) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	service, method := SplitMethod(fullMethod)
+	span.SetAttributes(
+		semconv.RPCSystemGRPC,
+		semconv.RPCService(service),
+		semconv.RPCMethod(method),
+		semconv.ServiceInstanceID(config.Conf.Config().Frame.Instance),
+	)
+}
+
+// 为当前span设置对端地址
+func SetPeerSpanAttributes(ctx context.Context, p *peer.Peer) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() || p == nil || p.Addr == nil {
+		return
+	}
+	host, port, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		span.SetAttributes(semconv.NetworkPeerAddress(p.Addr.String()))
+		return
+	}
+	portNum, _ := strconv.Atoi(port)
+	span.SetAttributes(semconv.NetworkPeerAddress(host), semconv.NetworkPeerPort(portNum))
+}
+
+// 为当前span设置 rpc 状态码. 客户端所有非OK的状态码都视为错误, 服务端只有服务自身的错误才视为错误
+func SetRpcSpanStatus(ctx context.Context, err error, isServer bool) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	code := status.Code(err)
+	span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(code)))
+	if code == codes.OK {
+		return
+	}
+	if !isServer || isServerErrCode(code) {
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+}
+
+func isServerErrCode(code codes.Code) bool {
+	switch code {
+	case codes.Unknown, codes.DeadlineExceeded, codes.Unimplemented, codes.Internal, codes.Unavailable, codes.DataLoss:
+		return true
+	}
+	return false
+}
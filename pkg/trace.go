@@ -6,7 +6,6 @@ import (
 
 	"github.com/bytedance/sonic"
 	"github.com/zly-app/zapp/filter"
-	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/propagation"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
@@ -22,7 +21,7 @@ func TraceInjectIn(ctx context.Context) (context.Context, metadata.MD) {
 	// 取出 in 元数据
 	mdIn, _ := metadata.FromIncomingContext(ctx)
 	tm := TextMapCarrier{mdIn}
-	ctx = otel.GetTextMapPropagator().Extract(ctx, tm)
+	ctx = GetPropagator(ctx).Extract(ctx, tm)
 	return ctx, mdIn
 }
 
@@ -38,7 +37,7 @@ func TraceInjectOut(ctx context.Context) (context.Context, metadata.MD) {
 	}
 
 	tm := TextMapCarrier{mdOut}
-	otel.GetTextMapPropagator().Inject(ctx, tm)
+	GetPropagator(ctx).Inject(ctx, tm)
 	ctx = metadata.NewOutgoingContext(ctx, mdOut)
 	return ctx, mdOut
 }
@@ -52,7 +51,7 @@ func TraceInjectGrpcHeader(ctx context.Context, opts ...grpc.CallOption) {
 				*opt.HeaderAddr = metadata.MD{}
 			}
 			tm := TextMapCarrier{*opt.HeaderAddr}
-			otel.GetTextMapPropagator().Inject(ctx, tm)
+			GetPropagator(ctx).Inject(ctx, tm)
 		}
 	}
 }
@@ -0,0 +1,37 @@
+package policy
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/zly-app/zapp/log"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// 认证元数据key
+const AuthorizationMDataKey = "authorization"
+
+// 认证函数, 返回的ctx会传递给处理函数, 可以在其中保存用户信息. 认证失败时应返回 codes.Unauthenticated 或 codes.PermissionDenied 错误
+type AuthFunc = func(ctx context.Context, fullMethod string) (context.Context, error)
+
+var authFunc atomic.Pointer[AuthFunc]
+
+// 设置认证函数, 设置了 auth_required 的方法会调用它. 未设置时这些方法的请求都会返回 codes.Unauthenticated
+func SetAuthFunc(fn AuthFunc) {
+	if fn == nil {
+		authFunc.Store(nil)
+		return
+	}
+	authFunc.Store(&fn)
+}
+
+func authenticate(ctx context.Context, fullMethod string) (context.Context, error) {
+	fn := authFunc.Load()
+	if fn == nil {
+		log.Error(ctx, "方法需要认证, 但未设置认证函数, 请通过 SetAuthFunc 设置", zap.String("method", fullMethod))
+		return ctx, status.Error(codes.Unauthenticated, "服务未配置认证")
+	}
+	return (*fn)(ctx, fullMethod)
+}
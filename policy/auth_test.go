@@ -0,0 +1,93 @@
+package policy
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/zly-app/grpc/options"
+)
+
+func TestAuthenticate(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(AuthorizationMDataKey, "x"))
+
+	// 未设置认证函数时拒绝请求
+	SetAuthFunc(nil)
+	if _, err := authenticate(ctx, "/a.b/C"); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("未设置认证函数时应返回 Unauthenticated, 实际为 %v", err)
+	}
+
+	// 设置认证函数时由认证函数决定
+	type userKey struct{}
+	SetAuthFunc(func(ctx context.Context, fullMethod string) (context.Context, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		if vs := md.Get(AuthorizationMDataKey); len(vs) == 0 || vs[0] != "token" {
+			return ctx, status.Error(codes.Unauthenticated, "无效的token")
+		}
+		return context.WithValue(ctx, userKey{}, "u1"), nil
+	})
+	defer SetAuthFunc(nil)
+
+	if _, err := authenticate(ctx, "/a.b/C"); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("无效的token应返回 Unauthenticated, 实际为 %v", err)
+	}
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs(AuthorizationMDataKey, "token"))
+	ctx, err := authenticate(ctx, "/a.b/C")
+	if err != nil {
+		t.Fatalf("认证失败: %v", err)
+	}
+	if ctx.Value(userKey{}) != "u1" {
+		t.Fatal("认证函数返回的ctx没有传递")
+	}
+}
+
+type testServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *testServerStream) Context() context.Context { return s.ctx }
+
+func TestStreamServerInterceptorAuth(t *testing.T) {
+	const method = "/a.b/Watch"
+	policyCache.Store(method, newPolicy(method, &options.MethodOptions{AuthRequired: true}))
+	defer policyCache.Delete(method)
+
+	interceptor := StreamServerInterceptor()
+	info := &grpc.StreamServerInfo{FullMethod: method, IsServerStream: true}
+	called := false
+	handler := func(srv interface{}, ss grpc.ServerStream) error {
+		called = true
+		return nil
+	}
+	ss := &testServerStream{ctx: metadata.NewIncomingContext(context.Background(), metadata.Pairs(AuthorizationMDataKey, "x"))}
+
+	// 未设置认证函数时拒绝请求
+	SetAuthFunc(nil)
+	if err := interceptor(nil, ss, info, handler); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("未设置认证函数时应返回 Unauthenticated, 实际为 %v", err)
+	}
+	if called {
+		t.Fatal("认证失败时不应调用处理函数")
+	}
+
+	// 认证函数返回的ctx传递给流
+	type userKey struct{}
+	SetAuthFunc(func(ctx context.Context, fullMethod string) (context.Context, error) {
+		return context.WithValue(ctx, userKey{}, "u1"), nil
+	})
+	defer SetAuthFunc(nil)
+	err := interceptor(nil, ss, info, func(srv interface{}, ss grpc.ServerStream) error {
+		if ss.Context().Value(userKey{}) != "u1" {
+			t.Fatal("认证函数返回的ctx没有传递给流")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("认证失败: %v", err)
+	}
+}
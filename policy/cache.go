@@ -0,0 +1,120 @@
+package policy
+
+import (
+	"context"
+	"encoding/binary"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/zly-app/grpc/pkg"
+)
+
+// 客户端响应缓存
+type responseCache struct {
+	mx      sync.Mutex
+	items   map[string]*cacheItem
+	maxSize int
+}
+
+type cacheItem struct {
+	rsp    proto.Message
+	expire time.Time
+}
+
+func newResponseCache(maxSize int) *responseCache {
+	return &responseCache{items: make(map[string]*cacheItem), maxSize: maxSize}
+}
+
+/*
+缓存key, 方法全名+调用方元数据+请求内容, 请求不是proto消息时返回false.
+
+调用方元数据包括认证信息 authorization 及透传数据, 不同调用方的响应不会共用缓存.
+其它元数据不参与计算, 响应依赖其它元数据的方法不能设置 cacheable
+*/
+func cacheKey(ctx context.Context, method string, req interface{}) (string, bool) {
+	m, ok := req.(proto.Message)
+	if !ok {
+		return "", false
+	}
+	bs, err := proto.MarshalOptions{Deterministic: true}.Marshal(m)
+	if err != nil {
+		return "", false
+	}
+
+	md, _ := metadata.FromOutgoingContext(ctx)
+	keys := make([]string, 0, len(md))
+	for k := range md {
+		if k == AuthorizationMDataKey || pkg.IsCustomDataMDataKey(k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	// 各部分使用长度前缀, 避免拼接后产生歧义
+	key := make([]byte, 0, len(method)+len(bs)+64)
+	key = appendCacheKeyPart(key, method)
+	for _, k := range keys {
+		key = appendCacheKeyPart(key, k)
+		key = binary.AppendUvarint(key, uint64(len(md[k])))
+		for _, v := range md[k] {
+			key = appendCacheKeyPart(key, v)
+		}
+	}
+	key = append(key, bs...)
+	return string(key), true
+}
+
+func appendCacheKeyPart(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// 从缓存中获取响应并写入 reply
+func (c *responseCache) get(key string, reply interface{}) bool {
+	out, ok := reply.(proto.Message)
+	if !ok {
+		return false
+	}
+
+	c.mx.Lock()
+	item, ok := c.items[key]
+	if ok && time.Now().After(item.expire) {
+		delete(c.items, key)
+		ok = false
+	}
+	c.mx.Unlock()
+	if !ok || item.rsp.ProtoReflect().Descriptor() != out.ProtoReflect().Descriptor() {
+		return false
+	}
+
+	proto.Reset(out)
+	proto.Merge(out, item.rsp)
+	return true
+}
+
+// 缓存响应, 缓存满时先清理过期的响应, 仍然满时不缓存
+func (c *responseCache) put(key string, reply interface{}, ttl time.Duration) {
+	rsp, ok := reply.(proto.Message)
+	if !ok {
+		return
+	}
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if len(c.items) >= c.maxSize {
+		now := time.Now()
+		for k, item := range c.items {
+			if now.After(item.expire) {
+				delete(c.items, k)
+			}
+		}
+		if len(c.items) >= c.maxSize {
+			return
+		}
+	}
+	c.items[key] = &cacheItem{rsp: proto.Clone(rsp), expire: time.Now().Add(ttl)}
+}
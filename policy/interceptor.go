@@ -0,0 +1,112 @@
+package policy
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// 服务端方法选项拦截器, 按方法选项限流, 认证及设置超时
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	var limiters sync.Map // 方法全名 -> *limiter
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, cancel, err := serverPolicy(ctx, &limiters, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		defer cancel()
+		return handler(ctx, req)
+	}
+}
+
+// 服务端流方法选项拦截器, 按方法选项限流, 认证及设置超时. 超时为整个流的最长时间
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	var limiters sync.Map // 方法全名 -> *limiter
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, cancel, err := serverPolicy(ss.Context(), &limiters, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		defer cancel()
+		return handler(srv, &grpc_middleware.WrappedServerStream{ServerStream: ss, WrappedContext: ctx})
+	}
+}
+
+// 服务端按方法选项限流, 认证及设置超时
+func serverPolicy(ctx context.Context, limiters *sync.Map, fullMethod string) (context.Context, context.CancelFunc, error) {
+	p := getPolicy(fullMethod)
+
+	if rl := p.opts.GetRateLimit(); rl.GetQps() > 0 {
+		v, ok := limiters.Load(fullMethod)
+		if !ok {
+			v, _ = limiters.LoadOrStore(fullMethod, newLimiter(rl.GetQps(), rl.GetBurst()))
+		}
+		if !v.(*limiter).allow() {
+			return ctx, nil, status.Errorf(codes.ResourceExhausted, "请求过于频繁, 方法 %s 限流 %d qps", fullMethod, rl.GetQps())
+		}
+	}
+
+	if p.opts.GetAuthRequired() {
+		var err error
+		ctx, err = authenticate(ctx, fullMethod)
+		if err != nil {
+			return ctx, nil, err
+		}
+	}
+
+	ctx, cancel := withTimeout(ctx, p.timeout)
+	return ctx, cancel, nil
+}
+
+// 客户端方法选项拦截器, 按方法选项缓存响应, 设置超时及重试
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	cache := newResponseCache(defMaxCacheSize)
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		p := getPolicy(method)
+
+		var key string
+		cacheable := false
+		if p.opts.GetCacheable() {
+			key, cacheable = cacheKey(ctx, method, req)
+			if cacheable && cache.get(key, reply) {
+				return nil
+			}
+		}
+
+		ctx, cancel := withTimeout(ctx, p.timeout)
+		defer cancel()
+
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		for attempt := 1; err != nil && attempt < p.maxAttempts && p.isRetryable(status.Code(err)); attempt++ {
+			t := time.NewTimer(p.backoff(attempt))
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return err
+			case <-t.C:
+			}
+			err = invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		if err == nil && cacheable {
+			cache.put(key, reply, p.cacheTtl)
+		}
+		return err
+	}
+}
+
+// 设置超时, ctx 已有更早的截止时间时不变
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	if dl, ok := ctx.Deadline(); ok && time.Until(dl) <= timeout {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
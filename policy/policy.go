@@ -0,0 +1,115 @@
+/*
+按 proto 中定义的方法选项 (zapp.grpc.method) 在运行时生效的策略.
+
+服务端: 超时, 认证, 限流
+客户端: 超时, 重试, 缓存响应
+*/
+package policy
+
+import (
+	"sync"
+	"time"
+
+	"github.com/zly-app/zapp/log"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+
+	"github.com/zly-app/grpc/options"
+	"github.com/zly-app/grpc/pkg"
+)
+
+const (
+	// 第一次重试前的等待时间
+	defRetryInitialBackoff = 100 * time.Millisecond
+	// 重试的最大等待时间
+	defRetryMaxBackoff = time.Second
+	// 缓存时间
+	defCacheTtl = time.Second
+	// 客户端最多缓存的响应数
+	defMaxCacheSize = 10000
+)
+
+// 从方法选项解析后的策略
+type methodPolicy struct {
+	opts *options.MethodOptions
+
+	timeout time.Duration
+
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	retryCodes     map[codes.Code]struct{}
+
+	cacheTtl time.Duration
+}
+
+// 方法全名 -> *methodPolicy
+var policyCache sync.Map
+
+func getPolicy(fullMethod string) *methodPolicy {
+	if v, ok := policyCache.Load(fullMethod); ok {
+		return v.(*methodPolicy)
+	}
+	v, _ := policyCache.LoadOrStore(fullMethod, newPolicy(fullMethod, options.Lookup(fullMethod)))
+	return v.(*methodPolicy)
+}
+
+func newPolicy(fullMethod string, opts *options.MethodOptions) *methodPolicy {
+	p := &methodPolicy{
+		opts:           opts,
+		timeout:        time.Duration(opts.GetTimeoutMs()) * time.Millisecond,
+		maxAttempts:    int(opts.GetRetry().GetMaxAttempts()),
+		initialBackoff: time.Duration(opts.GetRetry().GetInitialBackoffMs()) * time.Millisecond,
+		maxBackoff:     time.Duration(opts.GetRetry().GetMaxBackoffMs()) * time.Millisecond,
+		cacheTtl:       time.Duration(opts.GetCacheTtlMs()) * time.Millisecond,
+	}
+	if p.initialBackoff <= 0 {
+		p.initialBackoff = defRetryInitialBackoff
+	}
+	if p.maxBackoff <= 0 {
+		p.maxBackoff = defRetryMaxBackoff
+	}
+	if p.cacheTtl <= 0 {
+		p.cacheTtl = defCacheTtl
+	}
+
+	p.retryCodes = make(map[codes.Code]struct{})
+	for _, s := range opts.GetRetry().GetRetryableCodes() {
+		c, err := pkg.ParseCode(s)
+		if err != nil {
+			log.Warn("grpc 方法选项中的重试错误码无效", zap.String("method", fullMethod), zap.Error(err))
+			continue
+		}
+		p.retryCodes[c] = struct{}{}
+	}
+	if len(p.retryCodes) == 0 {
+		p.retryCodes[codes.Unavailable] = struct{}{}
+	}
+	// 非幂等方法只在请求未被处理时重试
+	if !opts.GetIdempotent() {
+		_, ok := p.retryCodes[codes.Unavailable]
+		p.retryCodes = make(map[codes.Code]struct{})
+		if ok {
+			p.retryCodes[codes.Unavailable] = struct{}{}
+		}
+	}
+	return p
+}
+
+// 是否可以重试这个错误码
+func (p *methodPolicy) isRetryable(c codes.Code) bool {
+	_, ok := p.retryCodes[c]
+	return ok
+}
+
+// 第n次重试前的等待时间, n从1开始
+func (p *methodPolicy) backoff(n int) time.Duration {
+	d := p.initialBackoff
+	for i := 1; i < n && d < p.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > p.maxBackoff {
+		d = p.maxBackoff
+	}
+	return d
+}
@@ -0,0 +1,162 @@
+package policy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/zly-app/grpc/options"
+	"github.com/zly-app/grpc/pkg"
+)
+
+func TestNewPolicy(t *testing.T) {
+	p := newPolicy("/a.b/C", &options.MethodOptions{})
+	if p.timeout != 0 || p.initialBackoff != defRetryInitialBackoff || p.maxBackoff != defRetryMaxBackoff || p.cacheTtl != defCacheTtl {
+		t.Fatalf("默认值错误: %+v", p)
+	}
+	if !p.isRetryable(codes.Unavailable) || p.isRetryable(codes.Internal) {
+		t.Fatal("默认只重试 Unavailable")
+	}
+
+	opts := &options.MethodOptions{
+		TimeoutMs:  500,
+		Idempotent: true,
+		Retry: &options.RetryPolicy{
+			MaxAttempts:      3,
+			InitialBackoffMs: 10,
+			MaxBackoffMs:     50,
+			RetryableCodes:   []string{"RESOURCE_EXHAUSTED", "14", "invalid"},
+		},
+		CacheTtlMs: 200,
+	}
+	p = newPolicy("/a.b/C", opts)
+	if p.timeout != 500*time.Millisecond || p.maxAttempts != 3 || p.initialBackoff != 10*time.Millisecond ||
+		p.maxBackoff != 50*time.Millisecond || p.cacheTtl != 200*time.Millisecond {
+		t.Fatalf("解析选项错误: %+v", p)
+	}
+	if !p.isRetryable(codes.ResourceExhausted) || !p.isRetryable(codes.Unavailable) || len(p.retryCodes) != 2 {
+		t.Fatalf("重试错误码错误: %v", p.retryCodes)
+	}
+
+	// 非幂等方法只在 Unavailable 时重试
+	opts.Idempotent = false
+	p = newPolicy("/a.b/C", opts)
+	if !p.isRetryable(codes.Unavailable) || p.isRetryable(codes.ResourceExhausted) {
+		t.Fatalf("非幂等方法的重试错误码错误: %v", p.retryCodes)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := newPolicy("/a.b/C", &options.MethodOptions{Retry: &options.RetryPolicy{InitialBackoffMs: 100, MaxBackoffMs: 300}})
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond}
+	for i, w := range want {
+		if d := p.backoff(i + 1); d != w {
+			t.Fatalf("第%d次重试的等待时间错误: %v, 期望 %v", i+1, d, w)
+		}
+	}
+}
+
+func TestLimiterAllow(t *testing.T) {
+	l := newLimiter(10, 2)
+	if !l.allow() || !l.allow() {
+		t.Fatal("突发请求应被允许")
+	}
+	if l.allow() {
+		t.Fatal("令牌用完后应被限流")
+	}
+	l.last = l.last.Add(-100 * time.Millisecond) // 经过0.1秒生成1个令牌
+	if !l.allow() {
+		t.Fatal("生成令牌后应被允许")
+	}
+	if l.allow() {
+		t.Fatal("令牌用完后应被限流")
+	}
+
+	// burst 默认等于 qps
+	if l = newLimiter(3, 0); l.burst != 3 {
+		t.Fatalf("默认 burst 错误: %v", l.burst)
+	}
+}
+
+func TestResponseCacheExpire(t *testing.T) {
+	c := newResponseCache(1)
+	c.put("a", wrapperspb.String("1"), 50*time.Millisecond)
+
+	out := &wrapperspb.StringValue{}
+	if !c.get("a", out) || out.GetValue() != "1" {
+		t.Fatal("应命中缓存")
+	}
+	if c.get("a", &wrapperspb.Int32Value{}) {
+		t.Fatal("响应类型不同时不应命中缓存")
+	}
+
+	// 缓存满时不缓存
+	c.put("b", wrapperspb.String("2"), time.Minute)
+	if c.get("b", out) {
+		t.Fatal("缓存满时不应缓存")
+	}
+
+	// 过期后不命中, 并且可以缓存新的响应
+	time.Sleep(60 * time.Millisecond)
+	if c.get("a", out) {
+		t.Fatal("过期后不应命中缓存")
+	}
+	c.put("b", wrapperspb.String("2"), time.Minute)
+	if !c.get("b", out) || out.GetValue() != "2" {
+		t.Fatal("过期清理后应可以缓存")
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	req := wrapperspb.String("req")
+	key := func(kv ...string) string {
+		ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs(kv...))
+		k, ok := cacheKey(ctx, "/a.b/C", req)
+		if !ok {
+			t.Fatal("proto请求应可以缓存")
+		}
+		return k
+	}
+
+	base := key("traceparent", "1", "x-request-id", "1")
+	if base != key("traceparent", "2", "x-request-id", "2") {
+		t.Fatal("trace及请求id不应影响缓存key")
+	}
+	if key(AuthorizationMDataKey, "u1") == key(AuthorizationMDataKey, "u2") {
+		t.Fatal("不同的认证信息不应共用缓存")
+	}
+	tenant := pkg.MakeCustomDataKey("tenant_id")
+	if key(tenant, "t1") == key(tenant, "t2") || key(tenant, "t1") == base {
+		t.Fatal("不同的透传数据不应共用缓存")
+	}
+	if _, ok := cacheKey(context.Background(), "/a.b/C", "not proto"); ok {
+		t.Fatal("非proto请求不应缓存")
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	ctx, cancel := withTimeout(context.Background(), 0)
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Fatal("超时为0时不应设置截止时间")
+	}
+
+	ctx, cancel = withTimeout(context.Background(), time.Second)
+	defer cancel()
+	if dl, ok := ctx.Deadline(); !ok || time.Until(dl) > time.Second {
+		t.Fatal("应设置截止时间")
+	}
+
+	// 已有更早的截止时间时不变
+	parent, parentCancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer parentCancel()
+	ctx, cancel = withTimeout(parent, time.Second)
+	defer cancel()
+	if ctx != parent {
+		t.Fatal("已有更早的截止时间时不应修改")
+	}
+}
@@ -0,0 +1,45 @@
+package policy
+
+import (
+	"sync"
+	"time"
+)
+
+// 令牌桶限流器
+type limiter struct {
+	mx     sync.Mutex
+	rate   float64 // 每秒生成的令牌数
+	burst  float64 // 桶容量
+	tokens float64
+	last   time.Time
+}
+
+func newLimiter(qps, burst uint32) *limiter {
+	if burst == 0 {
+		burst = qps
+	}
+	return &limiter{
+		rate:   float64(qps),
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// 获取一个令牌, 没有令牌时返回 false
+func (l *limiter) allow() bool {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
@@ -163,6 +163,17 @@ func (s *RedisRegistry) UnRegistry(ctx context.Context, serverName string) {
 	}
 }
 
+// 获取调试状态, serverName -> 注册数据
+func (s *RedisRegistry) DebugState() interface{} {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	ret := make(map[string]RegServer, len(s.servers))
+	for serverName, reg := range s.servers {
+		ret[serverName] = *reg
+	}
+	return ret
+}
+
 func (s *RedisRegistry) Close() {
 	if s.t != nil {
 		s.t.Stop()
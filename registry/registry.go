@@ -3,18 +3,22 @@ package registry
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	"github.com/zly-app/zapp/component/conn"
 	"github.com/zly-app/zapp/core"
 	"github.com/zly-app/zapp/handler"
 
+	"github.com/zly-app/grpc/admin"
 	"github.com/zly-app/grpc/pkg"
 )
 
 func init() {
 	handler.AddHandler(handler.AfterExitHandler, func(app core.IApp, handlerType handler.HandlerType) {
 		registryConn.CloseAll()
+		registries.Clear()
 	})
+	admin.RegisterState("registry", func() interface{} { return DebugStates() })
 }
 
 type Registry interface {
@@ -48,9 +52,32 @@ func GetRegistry(app core.IApp, registryType, registryName string) (Registry, er
 		return nil, err
 	}
 	r := ins.(Registry)
+	registries.Store(key, r)
 	return r, nil
 }
 
 func AddCreator(registryName string, r RegistryCreator) {
 	registryCreator[registryName] = r
 }
+
+// 可以输出调试状态的注册器
+type DebugStater interface {
+	DebugState() interface{}
+}
+
+// 已创建的注册器, key -> Registry
+var registries sync.Map
+
+// 获取所有已创建注册器的调试状态, key为 类型/地址
+func DebugStates() map[string]interface{} {
+	ret := make(map[string]interface{})
+	registries.Range(func(key, value any) bool {
+		if d, ok := value.(DebugStater); ok {
+			ret[key.(string)] = d.DebugState()
+		} else {
+			ret[key.(string)] = nil
+		}
+		return true
+	})
+	return ret
+}
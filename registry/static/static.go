@@ -84,6 +84,25 @@ func (s *StaticRegistry) UnRegistry(ctx context.Context, serverName string) {
 	defer s.mx.Unlock()
 
 	delete(s.address, serverName)
+	s.conn.Close(serverName) // 关闭缓存的 resolver, 重新注册后使用新的地址
+}
+
+// 获取服务的地址列表
+func (s *StaticRegistry) GetAddress(serverName string) []*pkg.AddrInfo {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return append([]*pkg.AddrInfo(nil), s.address[serverName]...)
+}
+
+// 获取调试状态, serverName -> 地址列表
+func (s *StaticRegistry) DebugState() interface{} {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	ret := make(map[string][]*pkg.AddrInfo, len(s.address))
+	for serverName, address := range s.address {
+		ret[serverName] = append([]*pkg.AddrInfo(nil), address...)
+	}
+	return ret
 }
 
 // 创建Manual
@@ -6,6 +6,11 @@ import (
 	"github.com/zly-app/zapp"
 	"google.golang.org/grpc"
 
+	"github.com/zly-app/grpc/admin"
+	"github.com/zly-app/grpc/fault"
+	"github.com/zly-app/grpc/metrics"
+	"github.com/zly-app/grpc/options"
+	"github.com/zly-app/grpc/policy"
 	"github.com/zly-app/grpc/server"
 )
 
@@ -39,3 +44,27 @@ func ServerDesc(hooks ...ServerHook) ServiceRegistrar {
 	}
 	return server.ServerDesc(h...)
 }
+
+// 获取 prometheus 文本格式的rpc指标http处理器
+var MetricsHandler = metrics.Handler
+
+// 启动管理http服务, 提供 pprof, channelz, 指标及调试状态
+var StartAdminServer = admin.StartServer
+
+// 获取管理http处理器, 可以挂载到自己的http服务上
+var AdminHandler = admin.Handler
+
+// 注册调试状态提供者, 可以通过 /debug/grpc/{name} 查看
+var RegisterAdminState = admin.RegisterState
+
+// 运行时开关故障注入, name 如 server/hello, client/hello, * 表示所有
+var SetFaultEnable = fault.SetEnable
+
+// 认证函数, 用于设置了 auth_required 方法选项的方法
+type AuthFunc = policy.AuthFunc
+
+// 设置认证函数, 未设置时只检查元数据中是否有 authorization
+var SetAuthFunc = policy.SetAuthFunc
+
+// 获取 proto 中定义的方法选项, fullMethod 如 /hello.HelloService/Say
+var LookupMethodOptions = options.Lookup
@@ -9,6 +9,11 @@
 package server
 
 import (
+	"google.golang.org/grpc/codes"
+
+	"github.com/zly-app/grpc/accesslog"
+	"github.com/zly-app/grpc/fault"
+	"github.com/zly-app/grpc/pkg"
 	"github.com/zly-app/grpc/registry/static"
 )
 
@@ -24,6 +29,19 @@ const (
 	// 是否对请求数据校验所有字段
 	defReqDataValidateAllField = false
 
+	// 是否启用rpc指标
+	defMetrics = false
+	// 是否启用proto中定义的方法选项
+	defMethodOptions = true
+
+	// 单个透传数据的最大字节数
+	defPassThroughMaxValueSize = 1024
+	// 透传数据的最大总字节数
+	defPassThroughMaxTotalSize = 8192
+
+	// 屏蔽错误时返回的消息
+	defErrorMaskMessage = "service internal error"
+
 	defRegistryAddress = static.Type
 	defWeight          = 100
 )
@@ -34,14 +52,40 @@ type ServerConfig struct {
 	HeartbeatTime                 int    // 心跳时间, 单位秒
 	ReqDataValidate               bool   // 是否启用请求数据校验
 	ReqDataValidateAllField       bool   // 是否对请求数据校验所有字段. 如果设为true, 会对所有字段校验并返回所有的错误. 如果设为false, 校验错误会立即返回.
-	SendDetailedErrorInProduction bool   // 在生产环境发送详细的错误到客户端. 如果设为 false, 在生产环境且错误状态码为 Unknown, 则会返回 service internal error 给客户端.
+	SendDetailedErrorInProduction bool   // 在生产环境发送详细的错误到客户端. 如果设为 false, 在生产环境且错误状态码在 ErrorMaskCodes 中, 则会返回 ErrorMaskMessage 给客户端.
 	TLSCertFile                   string // tls公钥文件路径
 	TLSKeyFile                    string // tls私钥文件路径
 
+	ErrorMaskCodes   []string // 生产环境需要屏蔽的错误码, 支持名称或数字, 如 Unknown, Internal, 13. 默认 Unknown
+	ErrorMaskMessage string   // 屏蔽后返回给客户端的消息, 默认 service internal error
+	ErrorMaskWithId  bool     // 屏蔽时是否附加错误id, 错误id会和原始错误一起记录到日志中, 便于根据用户反馈排查
+
+	Metrics     bool   // 是否启用rpc指标, 默认false. 指标会通过otel导出, 同时可以通过 MetricsBind 以 prometheus 文本格式导出
+	MetricsBind string // prometheus 指标http服务bind地址, 如 :9100, 指标路径为 /metrics. 为空表示不启动
+
+	AccessLog *accesslog.Config // 访问日志, 默认关闭
+	Fault     *fault.Config     // 故障注入, 用于混沌测试, 默认关闭
+
+	MethodOptions bool // 是否启用proto中定义的方法选项 (zapp.grpc.method), 包括超时, 认证和限流, 默认true
+	Reflection    bool // 是否注册grpc反射服务, 网关的动态转码可以通过反射获取proto描述, 默认false
+
+	PassThroughKeys         []string // 允许透传的自定义数据key, 如 tenant_id, user_id. 上游传入的这些数据会在请求下游时自动带上. 为空表示不透传
+	PassThroughMaxValueSize int      // 单个透传数据的最大字节数, 超过时丢弃, 默认1024, 小于1表示不限制
+	PassThroughMaxTotalSize int      // 透传数据的最大总字节数, 超过时丢弃之后的key, 默认8192, 小于1表示不限制
+
+	TracePropagators []string // trace传播器, 支持 tracecontext, baggage, b3, b3multi, jaeger. 为空表示使用otel全局传播器
+
+	AdminBind string // 管理http服务bind地址, 如 127.0.0.1:9101, 提供 pprof, channelz, 指标及服务端/客户端/注册/发现的调试状态. 为空表示不启动, 不要暴露到公网
+
+	DefaultLocale  string                       // 默认语言, 调用方未指定语言时使用, 如 zh-CN. 为空时只按调用方指定的语言翻译
+	MessageCatalog map[string]map[string]string // 消息目录, locale -> 业务错误码的 Reason 或 Code -> 消息
+
 	RegistryAddress string // 注册地址, 默认 static, 参考 https://github.com/zly-app/grpc/tree/master/registry
 	PublishName     string // 公告名, 在注册中心中定义的名称, 如果为空则自动设为 PublishAddress
 	PublishAddress  string // 公告地址, 在注册中心中定义的地址, 客户端会根据这个地址连接服务端, 如果为空则自动设为 实例ip:BindPort
 	PublishWeight   uint16 // 公告权重, 默认100
+
+	errorMaskCodes map[codes.Code]struct{}
 }
 
 func NewServerConfig() *ServerConfig {
@@ -49,6 +93,12 @@ func NewServerConfig() *ServerConfig {
 		HeartbeatTime:           defHeartbeatTime,
 		ReqDataValidate:         defReqDataValidate,
 		ReqDataValidateAllField: defReqDataValidateAllField,
+		Metrics:                 defMetrics,
+		MethodOptions:           defMethodOptions,
+		AccessLog:               accesslog.NewConfig(),
+		Fault:                   fault.NewConfig(),
+		PassThroughMaxValueSize: defPassThroughMaxValueSize,
+		PassThroughMaxTotalSize: defPassThroughMaxTotalSize,
 	}
 }
 
@@ -60,6 +110,34 @@ func (conf *ServerConfig) Check() error {
 		conf.HeartbeatTime = defMinHeartbeatTime
 	}
 
+	if len(conf.ErrorMaskCodes) == 0 {
+		conf.ErrorMaskCodes = []string{codes.Unknown.String()}
+	}
+	conf.errorMaskCodes = make(map[codes.Code]struct{}, len(conf.ErrorMaskCodes))
+	for _, s := range conf.ErrorMaskCodes {
+		c, err := pkg.ParseCode(s)
+		if err != nil {
+			return err
+		}
+		conf.errorMaskCodes[c] = struct{}{}
+	}
+	if conf.ErrorMaskMessage == "" {
+		conf.ErrorMaskMessage = defErrorMaskMessage
+	}
+
+	if conf.AccessLog == nil {
+		conf.AccessLog = accesslog.NewConfig()
+	}
+	if err := conf.AccessLog.Check(); err != nil {
+		return err
+	}
+	if conf.Fault == nil {
+		conf.Fault = fault.NewConfig()
+	}
+	if err := conf.Fault.Check(); err != nil {
+		return err
+	}
+
 	if conf.RegistryAddress == "" {
 		conf.RegistryAddress = defRegistryAddress
 	}
@@ -68,3 +146,9 @@ func (conf *ServerConfig) Check() error {
 	}
 	return nil
 }
+
+// 是否需要屏蔽这个错误码
+func (conf *ServerConfig) IsMaskCode(c codes.Code) bool {
+	_, ok := conf.errorMaskCodes[c]
+	return ok
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"sort"
+
+	"github.com/zly-app/grpc/admin"
+)
+
+func init() {
+	admin.RegisterState("servers", func() interface{} { return GetAllDebugState() })
+}
+
+// 服务端调试状态
+type DebugState struct {
+	Name     string
+	Config   *ServerConfig
+	Services []*ServiceState
+}
+
+// 服务状态
+type ServiceState struct {
+	Name    string
+	Methods []*MethodState
+}
+
+// 方法状态
+type MethodState struct {
+	Name           string
+	FullMethod     string
+	IsClientStream bool
+	IsServerStream bool
+}
+
+// 获取服务端调试状态
+func (g *GRpcServer) DebugState() *DebugState {
+	info := g.server.GetServiceInfo()
+	services := make([]*ServiceState, 0, len(info))
+	for name, si := range info {
+		s := &ServiceState{Name: name, Methods: make([]*MethodState, len(si.Methods))}
+		for i, m := range si.Methods {
+			s.Methods[i] = &MethodState{
+				Name:           m.Name,
+				FullMethod:     "/" + name + "/" + m.Name,
+				IsClientStream: m.IsClientStream,
+				IsServerStream: m.IsServerStream,
+			}
+		}
+		services = append(services, s)
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	return &DebugState{
+		Name:     g.serverName,
+		Config:   g.conf,
+		Services: services,
+	}
+}
+
+// 获取所有服务端的调试状态
+func GetAllDebugState() []*DebugState {
+	servers := defService.servers()
+	ret := make([]*DebugState, len(servers))
+	for i, g := range servers {
+		ret[i] = g.DebugState()
+	}
+	return ret
+}
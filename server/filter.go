@@ -4,19 +4,31 @@ import (
 	"context"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 
 	"github.com/zly-app/zapp/filter"
 
 	"github.com/zly-app/grpc/pkg"
 )
 
+// 提取上游传入的trace和请求id, 需要作为最外层的拦截器, 使访问日志和错误屏蔽等拦截器可以获取到
+func (g *GRpcServer) ExtractContextInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx = pkg.SavePropagator(ctx, g.propagator)
+	ctx, mdIn := pkg.TraceInjectIn(ctx)
+	ctx = pkg.ExtractRequestId(ctx, mdIn) // 请求id
+	return handler(ctx, req)
+}
+
 // 接入app filter
 func (g *GRpcServer) AppFilter(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 	ctx, chain := filter.GetServiceFilter(ctx, string(DefaultServiceType)+"."+g.serverName, info.FullMethod)
 	meta := filter.GetCallMeta(ctx)
 	meta.AddCallersSkip(3)
 
-	ctx, mdIn := pkg.TraceInjectIn(ctx)
+	mdIn, _ := metadata.FromIncomingContext(ctx)
+	ctx = pkg.ExtractGatewayData(ctx, mdIn) // 网关数据, 首次获取时解码
+	ctx = pkg.ExtractPassThroughData(ctx, mdIn, g.conf.PassThroughKeys, g.conf.PassThroughMaxValueSize, g.conf.PassThroughMaxTotalSize)
 
 	// 获取上游的主调信息并写入, 修改被调信息
 	callMeta, _ := pkg.ExtractCallerMetaFromMD(mdIn)
@@ -32,7 +44,12 @@ func (g *GRpcServer) AppFilter(ctx context.Context, req interface{}, info *grpc.
 	sp, err := chain.Handle(ctx, req, func(ctx context.Context, req interface{}) (interface{}, error) {
 		ctx, _ = pkg.TraceInjectOut(ctx)
 		ctx = filter.SaveCallerMeta(ctx, filter.CallerMeta{}) // 将上游携带的主调信息置空
+		pkg.SetRpcSpanAttributes(ctx, info.FullMethod)
+		if p, ok := peer.FromContext(ctx); ok {
+			pkg.SetPeerSpanAttributes(ctx, p)
+		}
 		sp, err := handler(ctx, req)
+		pkg.SetRpcSpanStatus(ctx, err, true)
 		if err != nil {
 			return nil, err
 		}
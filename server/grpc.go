@@ -2,6 +2,8 @@ package server
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"net"
 	"strings"
@@ -13,15 +15,24 @@ import (
 	"github.com/zly-app/zapp/filter"
 	"github.com/zly-app/zapp/handler"
 	"github.com/zly-app/zapp/log"
+	"github.com/zly-app/zapp/pkg/utils"
+	"go.opentelemetry.io/otel/propagation"
 	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc/status"
 
+	"github.com/zly-app/grpc/accesslog"
+	"github.com/zly-app/grpc/admin"
+	"github.com/zly-app/grpc/fault"
+	"github.com/zly-app/grpc/metrics"
 	"github.com/zly-app/grpc/pkg"
+	"github.com/zly-app/grpc/policy"
 	"github.com/zly-app/grpc/registry"
 	_ "github.com/zly-app/grpc/registry/redis"
 	"github.com/zly-app/grpc/registry/static"
@@ -35,6 +46,9 @@ type GRpcServer struct {
 	conf   *ServerConfig
 	server *grpc.Server
 
+	propagator propagation.TextMapPropagator
+	fault      *fault.Injector
+
 	serverName  string
 	serviceDesc *grpc.ServiceDesc
 }
@@ -47,10 +61,36 @@ func NewGRpcServer(app core.IApp, conf *ServerConfig, hooks ...ServerHook) (*GRp
 		app:  app,
 		conf: conf,
 	}
-	chainUnaryClientList := []grpc.UnaryServerInterceptor{
+	propagator, err := pkg.NewPropagator(conf.TracePropagators)
+	if err != nil {
+		return nil, err
+	}
+	g.propagator = propagator
+	g.fault, err = fault.NewInjector(conf.Fault)
+	if err != nil {
+		return nil, err
+	}
+	for locale, messages := range conf.MessageCatalog {
+		pkg.RegisterMessageCatalog(locale, messages)
+	}
+
+	chainUnaryClientList := make([]grpc.UnaryServerInterceptor, 0)
+	chainUnaryClientList = append(chainUnaryClientList, g.ExtractContextInterceptor) // 提取trace和请求id
+	if conf.Metrics {
+		chainUnaryClientList = append(chainUnaryClientList, metrics.UnaryServerInterceptor) // rpc指标
+	}
+	if conf.AccessLog.IsEnabled() {
+		chainUnaryClientList = append(chainUnaryClientList, accesslog.UnaryServerInterceptor(app, conf.AccessLog)) // 访问日志
+	}
+	chainUnaryClientList = append(chainUnaryClientList, fault.UnaryServerInterceptor(g.fault)) // 故障注入
+	if conf.MethodOptions {
+		chainUnaryClientList = append(chainUnaryClientList, policy.UnaryServerInterceptor()) // 方法选项
+	}
+	chainUnaryClientList = append(chainUnaryClientList,
 		ReturnErrorInterceptor(app, conf), // 返回错误拦截
+		LocalizeErrorInterceptor(conf),    // 错误消息本地化, 在返回错误拦截内, 被屏蔽的错误不会返回本地化消息
 		g.AppFilter,
-	}
+	)
 	if conf.ReqDataValidate && !conf.ReqDataValidateAllField {
 		chainUnaryClientList = append(chainUnaryClientList, UnaryServerReqDataValidateInterceptor)
 	}
@@ -67,14 +107,21 @@ func NewGRpcServer(app core.IApp, conf *ServerConfig, hooks ...ServerHook) (*GRp
 		cred = grpc.Creds(tc)
 	}
 
-	g.server = grpc.NewServer(
+	opts := []grpc.ServerOption{
 		cred,
 		grpc.KeepaliveParams(keepalive.ServerParameters{
 			Time: time.Duration(conf.HeartbeatTime) * time.Second, // 心跳
 		}),
 		grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(chainUnaryClientList...)),
 		grpc.ChainUnaryInterceptor(HookInterceptor(hooks...)), // 请求拦截
-	)
+	}
+	if conf.MethodOptions {
+		opts = append(opts, grpc.ChainStreamInterceptor(policy.StreamServerInterceptor())) // 流方法的方法选项
+	}
+	g.server = grpc.NewServer(opts...)
+	if conf.Reflection {
+		reflection.Register(g.server)
+	}
 	return g, nil
 }
 
@@ -82,6 +129,7 @@ func (g *GRpcServer) RegisterService(serverName string, desc *grpc.ServiceDesc,
 	g.server.RegisterService(desc, impl)
 	g.serverName = serverName
 	g.serviceDesc = desc
+	fault.Register("server/"+serverName, g.fault)
 }
 
 func (g *GRpcServer) parseRegistryAddress(address string) (string, string) {
@@ -103,6 +151,20 @@ func (g *GRpcServer) Start() error {
 		return fmt.Errorf("获取注册器失败: %v", err)
 	}
 
+	if g.conf.Metrics && g.conf.MetricsBind != "" {
+		if err = metrics.StartServer(g.app, g.conf.MetricsBind); err != nil {
+			log.Error("grpc 启动指标服务失败", zap.String("serverName", g.serverName), zap.Error(err))
+			return fmt.Errorf("启动指标服务失败: %v", err)
+		}
+	}
+
+	if g.conf.AdminBind != "" {
+		if err = admin.StartServer(g.app, g.conf.AdminBind); err != nil {
+			log.Error("grpc 启动管理服务失败", zap.String("serverName", g.serverName), zap.Error(err))
+			return fmt.Errorf("启动管理服务失败: %v", err)
+		}
+	}
+
 	// 开始监听
 	listener, err := net.Listen("tcp", g.conf.Bind)
 	if err != nil {
@@ -151,6 +213,11 @@ func (g *GRpcServer) Start() error {
 	return nil
 }
 
+// 在指定的 listener 上提供服务, 不会注册到注册中心, 阻塞直到服务关闭. 主要用于测试
+func (g *GRpcServer) Serve(listener net.Listener) error {
+	return g.server.Serve(listener)
+}
+
 func (g *GRpcServer) Close() {
 	g.server.GracefulStop()
 	g.app.Warn("grpc服务已关闭", zap.String("serverName", g.serverName))
@@ -158,7 +225,7 @@ func (g *GRpcServer) Close() {
 
 // 错误拦截
 func ReturnErrorInterceptor(app core.IApp, conf *ServerConfig) grpc.UnaryServerInterceptor {
-	interceptorUnknownErr := !app.GetConfig().Config().Frame.Debug && !conf.SendDetailedErrorInProduction // 是否拦截未定义的错误
+	maskErr := !app.GetConfig().Config().Frame.Debug && !conf.SendDetailedErrorInProduction // 是否屏蔽错误
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 		reply, err := handler(ctx, req)
 		if err == nil {
@@ -166,26 +233,123 @@ func ReturnErrorInterceptor(app core.IApp, conf *ServerConfig) grpc.UnaryServerI
 		}
 
 		code, codeType, err := filter.DefaultGetErrCodeFunc(ctx, reply, err)
-		if interceptorUnknownErr && err != nil && code == int(codes.Unknown) { // 拦截未定义错误
-			return reply, status.Error(codes.Internal, "service internal error")
+		if err == nil {
+			return reply, err
+		}
+
+		var st *status.Status
+		if s, ok := status.FromError(err); ok { // 被包装的status错误也需要保留错误码和详情
+			st = s
+		} else {
+			switch codeType {
+			case filter.CodeTypeTimeoutOrCancel:
+				st = status.New(codes.DeadlineExceeded, err.Error())
+			case filter.CodeTypeFail:
+				st = status.New(codes.Internal, err.Error())
+			case filter.CodeTypeException:
+				st = status.New(codes.Aborted, err.Error())
+			default:
+				st = status.New(codes.Code(code), err.Error())
+			}
+		}
+
+		if maskErr && conf.IsMaskCode(st.Code()) {
+			return nil, maskErrStatus(ctx, app, conf, info, st).Err()
 		}
+		return nil, st.Err()
+	}
+}
+
+// 屏蔽错误时附加的 ErrorInfo 的域
+const MaskedErrorDomain = "zapp.grpc"
+
+// 屏蔽错误, 原始错误会和错误id, traceId及requestId一起记录到日志
+func maskErrStatus(ctx context.Context, app core.IApp, conf *ServerConfig, info *grpc.UnaryServerInfo, st *status.Status) *status.Status {
+	traceId, _ := utils.Trace.GetOTELTraceID(ctx)
+	fields := []interface{}{
+		ctx, "grpc 错误已屏蔽",
+		zap.String("method", info.FullMethod),
+		zap.String("traceId", traceId),
+		zap.String("requestId", pkg.GetRequestId(ctx)),
+		zap.String("code", st.Code().String()),
+		zap.String("err", st.Message()),
+	}
+
+	msg := conf.ErrorMaskMessage
+	errorId := ""
+	if conf.ErrorMaskWithId {
+		errorId = newErrorId()
+		msg = fmt.Sprintf("%s (error_id: %s)", msg, errorId)
+		fields = append(fields, zap.String("errorId", errorId))
+	}
+	app.Error(fields...)
+
+	masked := keepErrDetails(status.New(codes.Internal, msg), dropLocalizedMessage(st).Err())
+	if errorId == "" {
+		return masked
+	}
+	err := pkg.WithErrorInfo(masked.Err(), "MASKED_ERROR", MaskedErrorDomain, map[string]string{"error_id": errorId})
+	return pkg.ErrToStatus(err)
+}
+
+// 移除本地化消息详情, 避免屏蔽后网关仍返回原始错误的消息
+func dropLocalizedMessage(st *status.Status) *status.Status {
+	p := st.Proto()
+	details := p.Details[:0]
+	for _, d := range p.Details {
+		if d.MessageIs((*errdetails.LocalizedMessage)(nil)) {
+			continue
+		}
+		details = append(details, d)
+	}
+	p.Details = details
+	return status.FromProto(p)
+}
+
+// 生成不透明的错误id
+func newErrorId() string {
+	bs := make([]byte, 8)
+	_, _ = rand.Read(bs)
+	return hex.EncodeToString(bs)
+}
 
-		if _, ok := err.(interface {
-			GRPCStatus() *status.Status
-		}); ok {
-			return nil, err
+// 错误消息本地化, 根据调用方的语言翻译业务错误码的消息, 并附加 LocalizedMessage 详情
+func LocalizeErrorInterceptor(conf *ServerConfig) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		reply, err := handler(ctx, req)
+		if err == nil {
+			return reply, err
 		}
 
-		switch codeType {
-		case filter.CodeTypeTimeoutOrCancel:
-			return nil, status.New(codes.DeadlineExceeded, err.Error()).Err()
-		case filter.CodeTypeFail:
-			return nil, status.New(codes.Internal, err.Error()).Err()
-		case filter.CodeTypeException:
-			return nil, status.New(codes.Aborted, err.Error()).Err()
+		b, ok := pkg.GetBizCodeByErr(err)
+		if !ok {
+			return reply, err
+		}
+		if _, ok = pkg.GetLocalizedMessage(err); ok {
+			return reply, err
 		}
-		return reply, err
+
+		locales := pkg.GetLocalesByIncoming(ctx)
+		if conf.DefaultLocale != "" {
+			locales = append(locales, conf.DefaultLocale)
+		}
+		msg, locale, ok := pkg.TranslateBizCode(locales, b)
+		if !ok {
+			return reply, err
+		}
+		return reply, pkg.WithLocalizedMessage(err, locale, msg)
+	}
+}
+
+// 将原始错误的详情附加到新的status上, 确保详情不会被丢弃
+func keepErrDetails(st *status.Status, err error) *status.Status {
+	details := pkg.ErrToStatus(err).Proto().GetDetails()
+	if len(details) == 0 {
+		return st
 	}
+	p := st.Proto()
+	p.Details = append(p.Details, details...)
+	return status.FromProto(p)
 }
 
 type ValidateInterface interface {
@@ -199,7 +363,7 @@ type ValidateAllInterface interface {
 func UnaryServerReqDataValidateInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 	if v, ok := req.(ValidateInterface); ok {
 		if err := v.Validate(); err != nil {
-			return nil, status.Error(codes.InvalidArgument, err.Error())
+			return nil, ValidateErrToStatus(req, err)
 		}
 	}
 	return handler(ctx, req)
@@ -213,7 +377,7 @@ func UnaryServerReqDataValidateAllInterceptor(ctx context.Context, req interface
 		return UnaryServerReqDataValidateInterceptor(ctx, req, info, handler)
 	}
 	if err := v.ValidateAll(); err != nil {
-		return nil, status.Error(codes.InvalidArgument, err.Error())
+		return nil, ValidateErrToStatus(req, err)
 	}
 	return handler(ctx, req)
 }
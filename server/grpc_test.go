@@ -0,0 +1,29 @@
+package server
+
+import (
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/zly-app/grpc/pkg"
+)
+
+func TestDropLocalizedMessage(t *testing.T) {
+	err := status.Error(codes.Unknown, "user not found")
+	err = pkg.WithLocalizedMessage(err, "zh-CN", "用户不存在")
+	err = pkg.WithErrorInfo(err, "USER_NOT_FOUND", "user", nil)
+
+	st := dropLocalizedMessage(pkg.ErrToStatus(err))
+	if _, ok := pkg.GetLocalizedMessage(st.Err()); ok {
+		t.Fatal("本地化消息应被移除")
+	}
+	if _, ok := pkg.GetErrorInfo(st.Err()); !ok {
+		t.Fatal("其它详情应被保留")
+	}
+
+	masked := keepErrDetails(status.New(codes.Internal, "service internal error"), st.Err())
+	if _, ok := pkg.GetLocalizedMessage(masked.Err()); ok {
+		t.Fatal("屏蔽后的错误不应包含本地化消息")
+	}
+}
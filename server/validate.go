@@ -0,0 +1,140 @@
+package server
+
+import (
+	"errors"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/zly-app/grpc/pkg"
+)
+
+// protoc-gen-validate 生成的单个字段校验错误
+type validationError interface {
+	Field() string
+	Reason() string
+	Cause() error
+	Key() bool
+}
+
+// protoc-gen-validate 生成的多个字段校验错误
+type validationMultiError interface {
+	AllErrors() []error
+}
+
+// 将数据校验错误转为带有 BadRequest 详情的 InvalidArgument 错误
+func ValidateErrToStatus(req interface{}, err error) error {
+	var desc protoreflect.MessageDescriptor
+	if m, ok := req.(proto.Message); ok {
+		desc = m.ProtoReflect().Descriptor()
+	}
+
+	violations := make([]*pkg.FieldViolation, 0)
+	collectFieldViolations(&violations, desc, "", err)
+
+	st := status.New(codes.InvalidArgument, err.Error())
+	if len(violations) == 0 {
+		return st.Err()
+	}
+	return pkg.WithBadRequest(st.Err(), violations...)
+}
+
+// 遍历校验错误, 收集所有字段的路径和原因
+func collectFieldViolations(out *[]*pkg.FieldViolation, desc protoreflect.MessageDescriptor, prefix string, err error) {
+	var multiErr validationMultiError
+	if errors.As(err, &multiErr) {
+		for _, e := range multiErr.AllErrors() {
+			collectFieldViolations(out, desc, prefix, e)
+		}
+		return
+	}
+
+	var vErr validationError
+	if !errors.As(err, &vErr) {
+		if err != nil {
+			*out = append(*out, &pkg.FieldViolation{Field: prefix, Description: err.Error()})
+		}
+		return
+	}
+
+	field, childDesc := resolveFieldPath(desc, vErr.Field())
+	if prefix != "" {
+		field = prefix + "." + field
+	}
+
+	// 嵌套消息的校验错误, 继续向下展开
+	cause := vErr.Cause()
+	if cause != nil && (errors.As(cause, &multiErr) || errors.As(cause, &vErr)) {
+		collectFieldViolations(out, childDesc, field, cause)
+		return
+	}
+
+	reason := vErr.Reason()
+	if cause != nil {
+		reason += ": " + cause.Error()
+	}
+	*out = append(*out, &pkg.FieldViolation{Field: field, Description: reason})
+}
+
+// 将 protoc-gen-validate 使用的go字段名转为proto字段名, 并返回该字段对应的消息描述
+func resolveFieldPath(desc protoreflect.MessageDescriptor, goField string) (string, protoreflect.MessageDescriptor) {
+	if desc == nil {
+		return goField, nil
+	}
+
+	name, suffix := goField, ""
+	if k := strings.IndexByte(goField, '['); k != -1 {
+		name, suffix = goField[:k], goField[k:]
+	}
+
+	fields := desc.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if goCamelCase(string(fd.Name())) != name {
+			continue
+		}
+
+		var child protoreflect.MessageDescriptor
+		switch {
+		case fd.IsMap():
+			child = fd.MapValue().Message()
+		default:
+			child = fd.Message()
+		}
+		return string(fd.Name()) + suffix, child
+	}
+	return goField, nil
+}
+
+// 与 protoc-gen-go 生成字段名的规则一致
+func goCamelCase(s string) string {
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '.' && i+1 < len(s) && isASCIILower(s[i+1]):
+		case c == '.':
+			b = append(b, '_')
+		case c == '_' && (i == 0 || s[i-1] == '.'):
+			b = append(b, 'X')
+		case c == '_' && i+1 < len(s) && isASCIILower(s[i+1]):
+		case isASCIIDigit(c):
+			b = append(b, c)
+		default:
+			if isASCIILower(c) {
+				c -= 'a' - 'A'
+			}
+			b = append(b, c)
+			for ; i+1 < len(s) && isASCIILower(s[i+1]); i++ {
+				b = append(b, s[i+1])
+			}
+		}
+	}
+	return string(b)
+}
+
+func isASCIILower(c byte) bool { return 'a' <= c && c <= 'z' }
+func isASCIIDigit(c byte) bool { return '0' <= c && c <= '9' }
@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"sync"
 
 	"github.com/zly-app/zapp"
 	"github.com/zly-app/zapp/core"
@@ -37,6 +38,7 @@ type ServiceAdapter struct {
 	app   core.IApp
 	hooks []ServerHook
 
+	mx        sync.RWMutex // 保护 server 和 serverMap
 	server    []*GRpcServer
 	serverMap map[string]*GRpcServer // serverName -> GRpcServer，同一 serverName 复用
 }
@@ -46,6 +48,9 @@ func (s *ServiceAdapter) Inject(a ...interface{}) {
 }
 
 func (s *ServiceAdapter) RegisterService(serverName string, desc *grpc.ServiceDesc, impl interface{}, hooks ...ServerHook) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
 	// 初始化 serverMap
 	if s.serverMap == nil {
 		s.serverMap = make(map[string]*GRpcServer)
@@ -74,12 +79,19 @@ func (s *ServiceAdapter) RegisterService(serverName string, desc *grpc.ServiceDe
 		log.Panic("创建grpc服务失败", zap.String("serverName", serverName), zap.Error(err))
 	}
 	g.RegisterService(serverName, desc, impl)
-	defService.server = append(defService.server, g)
-	defService.serverMap[serverName] = g
+	s.server = append(s.server, g)
+	s.serverMap[serverName] = g
+}
+
+// 获取所有服务端
+func (s *ServiceAdapter) servers() []*GRpcServer {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return append([]*GRpcServer(nil), s.server...)
 }
 
 func (s *ServiceAdapter) Start() error {
-	for _, g := range s.server {
+	for _, g := range s.servers() {
 		err := g.Start()
 		if err != nil {
 			return err
@@ -89,7 +101,7 @@ func (s *ServiceAdapter) Start() error {
 }
 
 func (s *ServiceAdapter) Close() error {
-	for _, g := range s.server {
+	for _, g := range s.servers() {
 		g.Close()
 	}
 	return nil